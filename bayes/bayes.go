@@ -0,0 +1,356 @@
+// Package bayes is a multinomial Naive Bayes spam classifier for short text
+// messages. A Classifier is trained on labeled examples, can be updated one
+// example at a time, saved and loaded, and evaluated on held out examples.
+package bayes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Class is the label of a message.
+type Class int
+
+const (
+	HamClass Class = iota
+	SpamClass
+)
+
+func (c Class) String() string {
+	switch c {
+	case HamClass:
+		return "ham"
+	case SpamClass:
+		return "spam"
+	default:
+		return ""
+	}
+}
+
+// MarshalText encodes the class by its name, so it reads as "ham" or "spam" in JSON.
+func (c Class) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Class) UnmarshalText(text []byte) error {
+	class, err := ClassType(string(text))
+	if err != nil {
+		return err
+	}
+	*c = class
+	return nil
+}
+
+func ClassType(str string) (Class, error) {
+	switch str {
+	case HamClass.String():
+		return HamClass, nil
+	case SpamClass.String():
+		return SpamClass, nil
+	default:
+		return HamClass, fmt.Errorf("invalid class: %s", str)
+	}
+}
+
+// Example is a labeled message.
+type Example struct {
+	Class Class
+	Text  string
+}
+
+// Options configures a Classifier.
+type Options struct {
+	// Tokenize splits a message into words, Words when nil. It is not saved
+	// with the model, so a loaded model must be given the same one.
+	Tokenize func(text string) []string
+	// Weigh returns how much every distinct word of a message counts, e.g.
+	// its TF-IDF weight. When nil a word counts as often as Tokenize finds
+	// it. Like Tokenize it is not saved with the model.
+	Weigh func(text string) map[string]float64
+	// Alpha is added to the count of every word, 1 (add one smoothing) when zero
+	Alpha float64
+	// ClassPrior weighs the classes by their share of the training messages,
+	// instead of treating ham and spam as equally likely
+	ClassPrior bool
+	// Preprocessors names the preprocessing the messages had before they were
+	// learned. The classifier does not apply it, callers repeat it before
+	// classifying.
+	//
+	// Alpha, ClassPrior and Preprocessors are saved with the model and Load
+	// restores them.
+	Preprocessors []string
+}
+
+// Words splits a message into its space separated words.
+func Words(text string) []string {
+	return strings.Fields(text)
+}
+
+// Smooth is the probability of a word in a class with additive smoothing: the
+// count of the word plus alpha, over the number of distinct words of the
+// class plus alpha times the size of the vocabulary.
+func Smooth(count float64, classWords, vocabulary int, alpha float64) float64 {
+	return (count + alpha) / (float64(classWords) + alpha*float64(vocabulary))
+}
+
+// Classifier is a multinomial Naive Bayes classifier with additive smoothing.
+// It is safe for concurrent use: any number of goroutines may classify while
+// others learn. The zero value is an untrained classifier with the default
+// options.
+type Classifier struct {
+	mu      sync.RWMutex
+	opts    Options
+	classes [2]classCounts
+	// vocabulary counts the occurrences of every word over both classes
+	vocabulary map[string]float64
+	// allowed, when set, holds the only words that are learned, see Restrict
+	allowed map[string]bool
+}
+
+// classCounts is what the classifier knows about the messages of one class.
+type classCounts struct {
+	Messages int                `json:"messages"`
+	Words    map[string]float64 `json:"words"`
+}
+
+// New returns an untrained classifier.
+func New(opts Options) *Classifier {
+	if opts.Alpha <= 0 {
+		opts.Alpha = 1
+	}
+	c := &Classifier{opts: opts}
+	c.reset()
+	return c
+}
+
+func (c *Classifier) reset() {
+	for i := range c.classes {
+		c.classes[i] = classCounts{Words: make(map[string]float64)}
+	}
+	c.vocabulary = make(map[string]float64)
+}
+
+func (c *Classifier) alpha() float64 {
+	if c.opts.Alpha <= 0 {
+		return 1
+	}
+	return c.opts.Alpha
+}
+
+// Weights returns how much every distinct word of the message counts when it
+// is learned or classified.
+func (c *Classifier) Weights(text string) map[string]float64 {
+	if c.opts.Weigh != nil {
+		return c.opts.Weigh(text)
+	}
+	tokenize := c.opts.Tokenize
+	if tokenize == nil {
+		tokenize = Words
+	}
+	weights := make(map[string]float64)
+	for _, word := range tokenize(text) {
+		weights[word]++
+	}
+	return weights
+}
+
+// Train replaces what the classifier knows with the examples.
+func (c *Classifier) Train(examples []Example) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset()
+	for _, e := range examples {
+		c.add(e.Class, e.Text, 1)
+	}
+}
+
+// Restrict limits what the classifier knows to the words of the vocabulary,
+// e.g. the words a feature selection kept: other words are forgotten and
+// left out of the messages learned from then on. A nil vocabulary lifts the
+// restriction. The vocabulary is saved with the model.
+func (c *Classifier) Restrict(vocabulary []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.restrict(vocabulary)
+}
+
+func (c *Classifier) restrict(vocabulary []string) {
+	if vocabulary == nil {
+		c.allowed = nil
+		return
+	}
+	c.allowed = make(map[string]bool, len(vocabulary))
+	for _, word := range vocabulary {
+		c.allowed[word] = true
+	}
+	for word := range c.vocabulary {
+		if !c.allowed[word] {
+			delete(c.vocabulary, word)
+			delete(c.classes[HamClass].Words, word)
+			delete(c.classes[SpamClass].Words, word)
+		}
+	}
+}
+
+// Learn adds a labeled message to what the classifier knows.
+func (c *Classifier) Learn(class Class, text string) error {
+	if err := checkClass(class); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(class, text, 1)
+	return nil
+}
+
+// Unlearn removes a message that was learned with the class before.
+func (c *Classifier) Unlearn(class Class, text string) error {
+	if err := checkClass(class); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.classes[class]
+	if counts.Messages == 0 {
+		return fmt.Errorf("unlearning %s message: no %s messages learned", class, class)
+	}
+	for word, n := range c.Weights(text) {
+		if c.learns(word) && counts.Words[word] < n-epsilon {
+			return fmt.Errorf("unlearning %s message: word %q was not learned as %s", class, word, class)
+		}
+	}
+	c.add(class, text, -1)
+	return nil
+}
+
+func checkClass(class Class) error {
+	if class != HamClass && class != SpamClass {
+		return fmt.Errorf("invalid class: %d", class)
+	}
+	return nil
+}
+
+// epsilon is the count below which a word is no longer known, so weights
+// that are learned and unlearned again do not leave rounding errors behind.
+const epsilon = 1e-9
+
+func (c *Classifier) learns(word string) bool {
+	return c.allowed == nil || c.allowed[word]
+}
+
+// add counts the message sign times, dropping words no message uses anymore.
+func (c *Classifier) add(class Class, text string, sign float64) {
+	if c.vocabulary == nil {
+		c.reset()
+	}
+	counts := &c.classes[class]
+	counts.Messages += int(sign)
+	for word, n := range c.Weights(text) {
+		if !c.learns(word) {
+			continue
+		}
+		counts.Words[word] += sign * n
+		if counts.Words[word] < epsilon {
+			delete(counts.Words, word)
+		}
+		c.vocabulary[word] += sign * n
+		if c.vocabulary[word] < epsilon {
+			delete(c.vocabulary, word)
+		}
+	}
+}
+
+// Classify returns the class the message most likely belongs to. A message
+// without any known word gets the class most training messages have.
+func (c *Classifier) Classify(text string) Class {
+	class, _ := c.Score(text)
+	return class
+}
+
+// SpamProbability returns the probability that the message is spam.
+func (c *Classifier) SpamProbability(text string) float64 {
+	_, p := c.Score(text)
+	return p
+}
+
+// Score returns both the class of the message and the probability that it is
+// spam, scoring the message once.
+func (c *Classifier) Score(text string) (Class, float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ham, spam := c.scores(text)
+	class := SpamClass
+	if ham > spam || ham == spam && c.classes[HamClass].Messages >= c.classes[SpamClass].Messages {
+		class = HamClass
+	}
+	return class, 1 / (1 + math.Exp(ham-spam))
+}
+
+// LogOdds returns the log of the spam probability of the message over its
+// ham probability.
+func (c *Classifier) LogOdds(text string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ham, spam := c.scores(text)
+	return spam - ham
+}
+
+// scores returns the log likelihood of the message for ham and spam respectively.
+func (c *Classifier) scores(text string) (float64, float64) {
+	var ham, spam float64
+	for word, weight := range c.Weights(text) {
+		// skip words that are not in the vocabulary
+		if _, exists := c.vocabulary[word]; !exists {
+			continue
+		}
+		ham += weight * math.Log(c.probability(HamClass, word))
+		spam += weight * math.Log(c.probability(SpamClass, word))
+	}
+	hamMessages, spamMessages := c.classes[HamClass].Messages, c.classes[SpamClass].Messages
+	if c.opts.ClassPrior && hamMessages > 0 && spamMessages > 0 {
+		ham += math.Log(float64(hamMessages))
+		spam += math.Log(float64(spamMessages))
+	}
+	return ham, spam
+}
+
+func (c *Classifier) probability(class Class, word string) float64 {
+	counts := c.classes[class]
+	return Smooth(counts.Words[word], len(counts.Words), len(c.vocabulary), c.alpha())
+}
+
+// Probability returns the smoothed probability of the word in the class, see
+// Smooth, and false for a word the classifier does not know.
+func (c *Classifier) Probability(class Class, word string) (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, exists := c.vocabulary[word]; !exists || checkClass(class) != nil {
+		return 0, false
+	}
+	return c.probability(class, word), true
+}
+
+// Preprocessors returns the names of the preprocessing the messages had
+// before they were learned, see Options.
+func (c *Classifier) Preprocessors() []string {
+	return append([]string(nil), c.opts.Preprocessors...)
+}
+
+// Messages returns the number of messages learned for the class.
+func (c *Classifier) Messages(class Class) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if checkClass(class) != nil {
+		return 0
+	}
+	return c.classes[class].Messages
+}
+
+// VocabularySize returns the number of distinct words learned.
+func (c *Classifier) VocabularySize() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.vocabulary)
+}
@@ -0,0 +1,237 @@
+package bayes_test
+
+import (
+	"bytes"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+)
+
+var examples = []bayes.Example{
+	{Class: bayes.HamClass, Text: "see you at lunch"},
+	{Class: bayes.HamClass, Text: "call me when you are home"},
+	{Class: bayes.HamClass, Text: "lunch is on me"},
+	{Class: bayes.SpamClass, Text: "win a free prize now"},
+	{Class: bayes.SpamClass, Text: "call now to claim your prize"},
+	{Class: bayes.SpamClass, Text: "free entry"},
+}
+
+func TestClassify(t *testing.T) {
+	c := bayes.New(bayes.Options{})
+	c.Train(examples)
+	if class := c.Classify("claim a free prize"); class != bayes.SpamClass {
+		t.Errorf("classifying spam: expected spam, got %s", class)
+	}
+	if class := c.Classify("see you at home"); class != bayes.HamClass {
+		t.Errorf("classifying ham: expected ham, got %s", class)
+	}
+	if p := c.SpamProbability("unknown words only"); p != 0.5 {
+		t.Errorf("spam probability without known words: expected 0.5, got %f", p)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	c := bayes.New(bayes.Options{})
+	c.Train(examples)
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+	loaded, err := bayes.Load(&buf, bayes.Options{})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	for _, text := range []string{"claim a free prize", "see you at home", "call me now"} {
+		if a, b := c.SpamProbability(text), loaded.SpamProbability(text); math.Abs(a-b) > 1e-12 {
+			t.Errorf("spam probability of %q after loading: expected %f, got %f", text, a, b)
+		}
+	}
+	if loaded.VocabularySize() != c.VocabularySize() || loaded.Messages(bayes.SpamClass) != 3 {
+		t.Errorf("loading: expected the same vocabulary and message counts")
+	}
+
+	if _, err := bayes.Load(bytes.NewBufferString(`{"version":2}`), bayes.Options{}); err == nil {
+		t.Errorf("loading an unknown version: expected an error")
+	}
+}
+
+func TestSaveLoadOptions(t *testing.T) {
+	opts := bayes.Options{Alpha: 0.1, ClassPrior: true, Preprocessors: []string{"stemmer"}}
+	c := bayes.New(opts)
+	c.Train(examples[:4])
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+	loaded, err := bayes.Load(&buf, bayes.Options{})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	if a, b := c.SpamProbability("free lunch"), loaded.SpamProbability("free lunch"); math.Abs(a-b) > 1e-12 {
+		t.Errorf("spam probability after loading with alpha and prior: expected %f, got %f", a, b)
+	}
+	if p := loaded.Preprocessors(); len(p) != 1 || p[0] != "stemmer" {
+		t.Errorf("loading preprocessors: expected [stemmer], got %v", p)
+	}
+	uniform := bayes.New(bayes.Options{Alpha: 0.1})
+	uniform.Train(examples[:4])
+	if c.SpamProbability("free lunch") >= uniform.SpamProbability("free lunch") {
+		t.Errorf("class prior with 3 ham and 1 spam: expected a lower spam probability than without")
+	}
+}
+
+func TestUnlearn(t *testing.T) {
+	trained := bayes.New(bayes.Options{})
+	trained.Train(examples)
+
+	c := bayes.New(bayes.Options{})
+	c.Train(examples)
+	if err := c.Learn(bayes.SpamClass, "free cash"); err != nil {
+		t.Fatalf("learning: %s", err)
+	}
+	if err := c.Unlearn(bayes.SpamClass, "free cash"); err != nil {
+		t.Fatalf("unlearning: %s", err)
+	}
+	if c.VocabularySize() != trained.VocabularySize() || c.SpamProbability("free lunch") != trained.SpamProbability("free lunch") {
+		t.Errorf("unlearning: expected the model it was trained to")
+	}
+	if err := c.Unlearn(bayes.HamClass, "free cash"); err == nil {
+		t.Errorf("unlearning words that were never learned: expected an error")
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var c bayes.Classifier
+	if class := c.Classify("free prize"); class != bayes.HamClass {
+		t.Errorf("classifying with an untrained classifier: expected ham, got %s", class)
+	}
+	for _, e := range examples {
+		if err := c.Learn(e.Class, e.Text); err != nil {
+			t.Fatalf("learning %q: %s", e.Text, err)
+		}
+	}
+	trained := bayes.New(bayes.Options{})
+	trained.Train(examples)
+	if a, b := trained.SpamProbability("claim a free prize"), c.SpamProbability("claim a free prize"); a != b {
+		t.Errorf("learning with the zero value: expected the spam probability of New, %f, got %f", a, b)
+	}
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("saving the zero value: %s", err)
+	}
+	if _, err := bayes.Load(&buf, bayes.Options{}); err != nil {
+		t.Errorf("loading the saved zero value: %s", err)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	c := bayes.New(bayes.Options{})
+	c.Restrict([]string{"free", "prize", "lunch"})
+	c.Train(examples)
+	if err := c.Learn(bayes.SpamClass, "cheap pills"); err != nil {
+		t.Fatalf("learning: %s", err)
+	}
+	if c.VocabularySize() != 3 {
+		t.Errorf("learning with a restricted vocabulary: expected 3 words, got %d", c.VocabularySize())
+	}
+	if _, known := c.Probability(bayes.SpamClass, "now"); known {
+		t.Errorf("probability of a word outside the vocabulary: expected it unknown")
+	}
+	if p, known := c.Probability(bayes.SpamClass, "free"); !known || p != bayes.Smooth(2, 2, 3, 1) {
+		t.Errorf("probability of free in spam: expected %f, got %f", bayes.Smooth(2, 2, 3, 1), p)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("saving: %s", err)
+	}
+	loaded, err := bayes.Load(&buf, bayes.Options{})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	loaded.Learn(bayes.SpamClass, "cheap now")
+	if loaded.VocabularySize() != 3 || loaded.SpamProbability("free lunch") != c.SpamProbability("free lunch") {
+		t.Errorf("loading a restricted model: expected it to stay restricted to 3 words, got %d", loaded.VocabularySize())
+	}
+}
+
+func TestWeigh(t *testing.T) {
+	// every word counts twice
+	double := func(text string) map[string]float64 {
+		weights := make(map[string]float64)
+		for _, word := range bayes.Words(text) {
+			weights[word] += 2
+		}
+		return weights
+	}
+	c := bayes.New(bayes.Options{Weigh: double})
+	c.Train(examples)
+	counted := bayes.New(bayes.Options{})
+	counted.Train(examples)
+	if p, _ := c.Probability(bayes.SpamClass, "free"); p != bayes.Smooth(4, 10, 20, 1) {
+		t.Errorf("weighted probability of free in spam: expected %f, got %f", bayes.Smooth(4, 10, 20, 1), p)
+	}
+	if a, b := c.LogOdds("free prize"), counted.LogOdds("free prize"); a <= b {
+		t.Errorf("log odds with doubled weights: expected more than %f, got %f", b, a)
+	}
+	class, p := c.Score("free prize")
+	if class != c.Classify("free prize") || p != c.SpamProbability("free prize") {
+		t.Errorf("scoring: expected the class and spam probability, got %s and %f", class, p)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := bayes.New(bayes.Options{})
+	c.Train(examples)
+	m := bayes.Evaluate(c, []bayes.Example{
+		{Class: bayes.SpamClass, Text: "claim your free prize"},
+		{Class: bayes.HamClass, Text: "see you at lunch"},
+		{Class: bayes.HamClass, Text: "free entry now"},
+	})
+	if m.TruePositive != 1 || m.TrueNegative != 1 || m.FalsePositive != 1 || m.FalseNegative != 0 {
+		t.Errorf("evaluating: got %+v", m)
+	}
+	if m.Accuracy() != 2.0/3 || m.Precision() != 0.5 || m.Recall() != 1 {
+		t.Errorf("metrics: expected accuracy 2/3, precision 1/2 and recall 1, got %f, %f and %f", m.Accuracy(), m.Precision(), m.Recall())
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	c := bayes.New(bayes.Options{})
+	c.Train(examples)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.Classify("claim a free prize")
+		}()
+		go func() {
+			defer wg.Done()
+			c.Learn(bayes.HamClass, "lunch at home")
+		}()
+	}
+	wg.Wait()
+	if c.Messages(bayes.HamClass) != 11 {
+		t.Errorf("learning concurrently: expected 11 ham messages, got %d", c.Messages(bayes.HamClass))
+	}
+}
+
+func BenchmarkTrain(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bayes.New(bayes.Options{}).Train(examples)
+	}
+}
+
+func BenchmarkClassify(b *testing.B) {
+	c := bayes.New(bayes.Options{})
+	c.Train(examples)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Classify(examples[i%len(examples)].Text)
+	}
+}
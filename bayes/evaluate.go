@@ -0,0 +1,65 @@
+package bayes
+
+// Metrics counts how the classifications of labeled messages turned out,
+// with spam as the positive class.
+type Metrics struct {
+	TruePositive  int
+	FalsePositive int
+	TrueNegative  int
+	FalseNegative int
+}
+
+// Evaluate classifies every example and compares the result to its label.
+func Evaluate(c *Classifier, examples []Example) Metrics {
+	var m Metrics
+	for _, e := range examples {
+		predicted := c.Classify(e.Text)
+		switch {
+		case predicted == SpamClass && e.Class == SpamClass:
+			m.TruePositive++
+		case predicted == SpamClass:
+			m.FalsePositive++
+		case e.Class == HamClass:
+			m.TrueNegative++
+		default:
+			m.FalseNegative++
+		}
+	}
+	return m
+}
+
+// Total is the number of evaluated examples.
+func (m Metrics) Total() int {
+	return m.TruePositive + m.FalsePositive + m.TrueNegative + m.FalseNegative
+}
+
+// Accuracy is the share of examples that were classified correctly.
+func (m Metrics) Accuracy() float64 {
+	return ratio(m.TruePositive+m.TrueNegative, m.Total())
+}
+
+// Precision is the share of messages classified as spam that are spam.
+func (m Metrics) Precision() float64 {
+	return ratio(m.TruePositive, m.TruePositive+m.FalsePositive)
+}
+
+// Recall is the share of spam messages that were classified as spam.
+func (m Metrics) Recall() float64 {
+	return ratio(m.TruePositive, m.TruePositive+m.FalseNegative)
+}
+
+// F1 is the harmonic mean of precision and recall.
+func (m Metrics) F1() float64 {
+	p, r := m.Precision(), m.Recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
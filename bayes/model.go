@@ -0,0 +1,90 @@
+package bayes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// modelVersion is written with every saved model, so a model saved in a
+// format this version cannot read is rejected instead of misread.
+const modelVersion = 1
+
+type model struct {
+	Version       int      `json:"version"`
+	Alpha         float64  `json:"alpha,omitempty"`
+	ClassPrior    bool     `json:"classPrior,omitempty"`
+	Preprocessors []string `json:"preprocessors,omitempty"`
+	// Vocabulary is the restriction of the classifier, see Restrict
+	Vocabulary []string    `json:"vocabulary,omitempty"`
+	Ham        classCounts `json:"ham"`
+	Spam       classCounts `json:"spam"`
+}
+
+// Save writes the model to w as JSON.
+func (c *Classifier) Save(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m := model{
+		Version:       modelVersion,
+		Alpha:         c.alpha(),
+		ClassPrior:    c.opts.ClassPrior,
+		Preprocessors: c.opts.Preprocessors,
+		Vocabulary:    c.restriction(),
+		Ham:           c.classes[HamClass],
+		Spam:          c.classes[SpamClass],
+	}
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		return fmt.Errorf("saving model: %w", err)
+	}
+	return nil
+}
+
+// Load reads a model written by Save, with the smoothing, prior and
+// preprocessor names it was saved with.
+func Load(r io.Reader, opts Options) (*Classifier, error) {
+	var m model
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("loading model: %w", err)
+	}
+	if m.Version != modelVersion {
+		return nil, fmt.Errorf("loading model: unsupported version %d", m.Version)
+	}
+	if m.Alpha < 0 {
+		return nil, fmt.Errorf("loading model: negative alpha %g", m.Alpha)
+	}
+	opts.Alpha, opts.ClassPrior, opts.Preprocessors = m.Alpha, m.ClassPrior, m.Preprocessors
+	c := New(opts)
+	for class, counts := range map[Class]classCounts{HamClass: m.Ham, SpamClass: m.Spam} {
+		if counts.Messages < 0 {
+			return nil, fmt.Errorf("loading model: negative %s message count", class)
+		}
+		c.classes[class].Messages = counts.Messages
+		for word, n := range counts.Words {
+			if n <= 0 {
+				return nil, fmt.Errorf("loading model: count %g of %s word %q", n, class, word)
+			}
+			c.classes[class].Words[word] = n
+			c.vocabulary[word] += n
+		}
+	}
+	if m.Vocabulary != nil {
+		c.restrict(m.Vocabulary)
+	}
+	return c, nil
+}
+
+// restriction returns the words the classifier is restricted to, sorted, or
+// nil when it learns every word.
+func (c *Classifier) restriction() []string {
+	if c.allowed == nil {
+		return nil
+	}
+	words := make([]string, 0, len(c.allowed))
+	for word := range c.allowed {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
@@ -1,68 +1,52 @@
 package analysis
 
 import (
-	"math"
-	"strings"
+	"fmt"
 
+	"github.com/andreas-holm/codecamp22/bayes"
 	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
 	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
 )
 
-var useTextMessageAsTest bool
-
 type Preprocessor interface {
 	Process(ex *experiment.Experiment)
 }
 
 type Analysis struct {
 	Name        string
+	Model       string
+	Classifier  Classifier
 	TrainingSet TrainingSet
 	TestSet     TestSet
 	FoundClass  experiment.Class
+	// TextMessage is the classified message as the classifier saw it, after
+	// preprocessing
+	TextMessage string
 }
 
-func (a Analysis) TestTestData(set experiment.TestSet) TestSet {
+// Evaluate classifies every test case in set with c and counts the correct and
+// incorrect classifications for each class.
+func Evaluate(c Classifier, set experiment.TestSet) TestSet {
 	//Create struct Testset
 	results := TestSet{
-		MessageTotal:          len(set.Cases),
-		CorrectHam:            0,
-		CorrectSpam:           0,
-		IncorrectHam:          0,
-		IncorrectSpam:         0,
-		PercentageCorrectHam:  0,
-		PercentageCorrectSpam: 0,
+		MessageTotal: len(set.Cases),
+		Predictions:  make([]Prediction, len(set.Cases)),
 	}
 
-	var hamScore float64
-	var spamScore float64
-	//loo over all sentences in the test data set
-	for _, sms := range set.Cases {
-		//Score to
-		hamScore, spamScore = 0, 0
-		//Split the message into seperate words
-		words := strings.Split(sms.Text, " ")
-		//Loop over all the words in a message
-		for _, word := range words {
-			// skip word if it isn't in the vocabulary
-			if !a.TrainingSet.Vocabulary.Contains(word) {
-				continue
-			}
-
-			// ham (add logs to prevent underflow of float with lots of multiplying)
-			//Log of a*b = log a + log b so this will be added not multiplicated
-			//Take wordprobability matrix for ham and check the probability for this word being in the class ham
-			hamScore = hamScore + math.Log10(a.TrainingSet.Ham.WordProbabilities[word])
-
-			// spam (add logs to prevent underflow of float with lots of multiplying)
-			//Take wordprobability matrix for Spam and check the probability for this word being in the class spam
-			spamScore = spamScore + math.Log10(a.TrainingSet.Spam.WordProbabilities[word])
+	//loop over all sentences in the test data set
+	for i, sms := range set.Cases {
+		predicted, probability := score(c, sms.Text)
+		results.Predictions[i] = Prediction{
+			Case:            i,
+			Line:            sms.Line,
+			Source:          sms.Source,
+			Class:           sms.Class,
+			Predicted:       predicted,
+			SpamProbability: probability,
+			Text:            sms.Text,
 		}
-		// When we got all the summed upp scores for all wordprobabilities we unlog
-		hamScore = math.Pow(10, hamScore)
-		spamScore = math.Pow(10, spamScore)
-
 		// if the algorithm says this is *ham*
-		if hamScore > spamScore {
+		if predicted == experiment.HamClass {
 			if sms.Class == experiment.HamClass {
 				results.CorrectHam = results.CorrectHam + 1
 			} else {
@@ -78,51 +62,12 @@ func (a Analysis) TestTestData(set experiment.TestSet) TestSet {
 	}
 	//Check percentages correct of each class which is correctClassifiedHam/(correctClassifiedHam + incorrectClassifiedSpam)
 	//For the percentage of correct ham
-	results.PercentageCorrectHam = float64(results.CorrectHam) / float64(results.CorrectHam+results.IncorrectSpam)
-	results.PercentageCorrectSpam = float64(results.CorrectSpam) / float64(results.CorrectSpam+results.IncorrectHam)
+	results.PercentageCorrectHam = ratio(results.CorrectHam, results.CorrectHam+results.IncorrectSpam)
+	results.PercentageCorrectSpam = ratio(results.CorrectSpam, results.CorrectSpam+results.IncorrectHam)
 
 	return results
 }
 
-func (a Analysis) TestTextMessage(ex experiment.Experiment) experiment.Class {
-
-	var hamScore float64
-	var spamScore float64
-	textMessage := ex.TextMessage
-	//Score to get calculated and compared which class it belongs to
-	hamScore, spamScore = 0, 0
-	//Split the message into seperate words
-	words := strings.Split(textMessage, " ")
-	//Loop over all the words in a message
-	for _, word := range words {
-		// skip word if it isn't in the vocabulary
-		if !a.TrainingSet.Vocabulary.Contains(word) {
-			continue
-		}
-
-		// ham (add logs to prevent underflow of float with lots of multiplying)
-		//Log of a*b = log a + log b so this will be added not multiplicated
-		//Take wordprobability matrix for ham and check the probability for this word being in the class ham
-		hamScore = hamScore + math.Log10(a.TrainingSet.Ham.WordProbabilities[word])
-
-		// spam (add logs to prevent underflow of float with lots of multiplying)
-		//Take wordprobability matrix for Spam and check the probability for this word being in the class spam
-		spamScore = spamScore + math.Log10(a.TrainingSet.Spam.WordProbabilities[word])
-	}
-	// When we got all the summed upp scores for all wordprobabilities we unlog
-	hamScore = math.Pow(10, hamScore)
-	spamScore = math.Pow(10, spamScore)
-
-	// if the algorithm says this is *ham*
-	if hamScore > spamScore {
-		return experiment.HamClass
-
-	} else { // algorithm says this is *spam*
-		return experiment.SpamClass
-	}
-
-}
-
 type TestSet struct {
 	MessageTotal          int
 	CorrectHam            int
@@ -131,6 +76,60 @@ type TestSet struct {
 	IncorrectSpam         int
 	PercentageCorrectHam  float64
 	PercentageCorrectSpam float64
+	// Predictions holds the classification of every test case, in order
+	Predictions []Prediction
+}
+
+// Prediction is how a classifier classified one test case.
+type Prediction struct {
+	// Case is the index of the test case, Line its line in the dataset and
+	// Source the file it was read from, if any
+	Case            int
+	Line            int
+	Source          string
+	Class           experiment.Class
+	Predicted       experiment.Class
+	SpamProbability float64
+	// Text is the test case as the classifier saw it, after preprocessing
+	Text string
+}
+
+// Location names where the test case was read from, e.g. "line 12" or
+// "spam/2022.mbox line 12".
+func (p Prediction) Location() string {
+	return location(p.Source, p.Line)
+}
+
+// location names a line of a dataset, and its file when it has several.
+func location(source string, line int) string {
+	if source == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s line %d", source, line)
+}
+
+// Correct tells for every test case, in order, whether it was classified
+// correctly, so classifiers tested on the same cases can be paired.
+func (t TestSet) Correct() []bool {
+	correct := make([]bool, len(t.Predictions))
+	for i, p := range t.Predictions {
+		correct[i] = p.Predicted == p.Class
+	}
+	return correct
+}
+
+// Accuracy is the share of test messages that were classified correctly, 0
+// without test messages.
+func (t TestSet) Accuracy() float64 {
+	return ratio(t.CorrectSpam+t.CorrectHam, t.MessageTotal)
+}
+
+// ratio is a over b, 0 when b is 0.
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
 }
 
 type TrainingSet struct {
@@ -138,6 +137,19 @@ type TrainingSet struct {
 	Spam         Class
 	Ham          Class
 	Vocabulary   Vocabulary
+	// Alpha is added to the count of every word when the probabilities of
+	// the words are compared, 1 (add one smoothing) when zero
+	Alpha float64
+
+	// selected is set when a feature selection chose the vocabulary
+	selected bool
+}
+
+func (ts TrainingSet) alpha() float64 {
+	if ts.Alpha <= 0 {
+		return 1
+	}
+	return ts.Alpha
 }
 
 type Vocabulary []string
@@ -153,28 +165,23 @@ func (v Vocabulary) Contains(word string) bool {
 
 type WordFrequency map[string]int
 
-func (wf WordFrequency) Probability(v Vocabulary) Probability {
-	//Vocabulary v is the list of all the existing words in the data
-	//Word frequency wf is a map of the frequency of all the words in THIS class of messages.
-	// I.E. in the SPAM class ["free" : 5]
-
-	//Create a probability matrix/map p
-	p := make(map[string]float64)
-
-	//Loop over every vocabWord in vocabulary v
-	for _, vocabWord := range v {
-		//For every words frequency divide by the sum of the length of the words existing for THIS class
-		//and the list of all the existing words in data file which will get the probability for this word
-		//for THIS class we are checking.
-		//Do this for every word and get probability map(matrix) for every word for this class.
-		//+1 is a smoothing variable performed by the github owner who created the algorithm
-		p[vocabWord] = float64(wf[vocabWord]+1) / float64(len(wf)+len(v))
-	}
-
-	return p
+// probability is the smoothed probability of a word of the vocabulary, as
+// the Naive Bayes model calculates it.
+func (wf WordFrequency) probability(word string, v Vocabulary, alpha float64) float64 {
+	return bayes.Smooth(float64(wf[word]), len(wf), len(v), alpha)
 }
 
-type Probability map[string]float64
+// restrict returns the frequencies of the words in the vocabulary only, so a
+// smaller vocabulary after feature selection still gives sensible probabilities.
+func (wf WordFrequency) restrict(v Vocabulary) WordFrequency {
+	restricted := make(WordFrequency, len(v))
+	for _, word := range v {
+		if frequency, exists := wf[word]; exists {
+			restricted[word] = frequency
+		}
+	}
+	return restricted
+}
 
 type Class struct {
 	// MessageTotal is the message total
@@ -183,102 +190,174 @@ type Class struct {
 	PofC float64
 	// WordFrequency represents words and how many times they occur in this class
 	WordFrequency WordFrequency
-	// WordProbabilities is a lookup of words and their probability of occurring in this class
-	WordProbabilities Probability
+	// DocumentFrequency represents words and how many messages of this class they occur in
+	DocumentFrequency DocumentFrequency
 }
 
 type Analyses []Analysis
 
-func Run(ex experiment.Experiment, usetextMessageFlag bool) Analyses {
+// Pipeline is a named list of preprocessors applied to a copy of the
+// experiment before the models are trained and tested on it.
+type Pipeline struct {
+	Name          string
+	Preprocessors []Preprocessor
+	// TFIDF, when set, makes the models that support it weight words with
+	// TF-IDF instead of raw counts.
+	TFIDF *TFIDFOptions
+	// Selection, when set, limits the vocabulary of the models that support it.
+	Selection *FeatureSelection
+}
+
+// DefaultPipelines returns the preprocessing pipelines Run compares.
+func DefaultPipelines() []Pipeline {
+	return []Pipeline{
+		{Name: "Default Analysis (no preprocessing)"},
+		{Name: "No Punctuation Analysis", Preprocessors: []Preprocessor{
+			parse.PreprocessRemovePunctuation{},
+		}},
+		{Name: "Stemmer Analysis", Preprocessors: []Preprocessor{
+			parse.PreprocessStemmer{},
+		}},
+		{Name: "Stemmer and No Punctuation Analysis", Preprocessors: []Preprocessor{
+			parse.PreprocessStemmer{},
+			parse.PreprocessRemovePunctuation{},
+		}},
+		{Name: "Remove 100 Most Common English Words", Preprocessors: []Preprocessor{
+			parse.PreprocessRemoveCommonWords{},
+		}},
+		{Name: "TF-IDF Analysis", TFIDF: &TFIDFOptions{Sublinear: true, Smooth: true}},
+	}
+}
+
+// Options tells Run which models to train on which pipelines, and what to do
+// with the trained models.
+type Options struct {
+	// Pipelines are the pipelines to compare, DefaultPipelines when empty
+	Pipelines []Pipeline
+	// Models are the models trained on every pipeline, DefaultModels when empty
+	Models []Model
+	// Evaluate tests the models on the test cases of the experiment instead
+	// of classifying its text message
+	Evaluate bool
+}
+
+// Run trains and tests every model on every pipeline, returning one analysis
+// per pipeline and model combination. It does not change ex, so it can be
+// called from several goroutines at once.
+func Run(ex experiment.Experiment, opts Options) Analyses {
+	pipelines, models := opts.Pipelines, opts.Models
+	if len(pipelines) == 0 {
+		pipelines = DefaultPipelines()
+	}
+	if len(models) == 0 {
+		models = DefaultModels()
+	}
 	var analyses Analyses
-	useTextMessageAsTest = usetextMessageFlag
-	// first analyze with no preprocessors
-	defaultAnalysis := analysisFrom(ex)
-	defaultAnalysis.Name = "Default Analysis (no preprocessing)"
-	analyses = append(analyses, defaultAnalysis)
-
-	// copy experiment for multiple types of preprocessing
-	ex2, ex3, ex4, ex5 := ex, ex, ex, ex
-
-	// analyze with punctuation removed
-	parse.PreprocessRemovePunctuation{}.Process(&ex2)
-	a2 := analysisFrom(ex2)
-	a2.Name = "No Punctuation Analysis"
-	analyses = append(analyses, a2)
-
-	// analyze with stemmer
-	parse.PreprocessStemmer{}.Process(&ex3)
-	a3 := analysisFrom(ex3)
-	a3.Name = "Stemmer Analysis"
-	analyses = append(analyses, a3)
-
-	// analyze with punctuation removed and then stemmer
-	parse.PreprocessStemmer{}.Process(&ex4)
-	parse.PreprocessRemovePunctuation{}.Process(&ex4)
-	a4 := analysisFrom(ex4)
-	a4.Name = "Stemmer and No Punctuation Analysis"
-	analyses = append(analyses, a4)
-
-	// analyze with common words removed
-	parse.PreprocessRemoveCommonWords{}.Process(&ex5)
-	a5 := analysisFrom(ex5)
-	a5.Name = "Remove 100 Most Common English Words"
-	analyses = append(analyses, a5)
+	for _, p := range pipelines {
+		// copy experiment so the preprocessing of one pipeline does not leak into the next
+		pex := ex.Copy()
+		for _, pre := range p.Preprocessors {
+			pre.Process(&pex)
+		}
+		var selection FeatureSelection
+		if p.Selection != nil {
+			selection = *p.Selection
+		}
+		trainingSet := newTrainingSet(pex.Classes, selection)
+		for _, m := range models {
+			analyses = append(analyses, analysisFrom(pex, p, trainingSet, m, opts.Evaluate))
+		}
+	}
 
 	return analyses
 }
 
-func analysisFrom(ex experiment.Experiment) Analysis {
-	//Total amount of training messages i.e. the sum of the length of the two classes in experiments
-	totalTrainingMessages := len(ex.Classes.Ham) + len(ex.Classes.Spam)
-	//Make a vocabulary, i.e. a list of all the words
-	vocabulary := vocabularyFrom(ex.Classes.Ham, ex.Classes.Spam)
-	//Calculate the word frequency map I.E. the frequency of every word in the messages of the class HAM.
-	hamFrequency := wordFrequencyFrom(ex.Classes.Ham)
-	//calculate the probability map(matrix) for every word to be in the HAM class.
-	hamProbabilities := hamFrequency.Probability(vocabulary)
-	//Calculate the word frequency map I.E. the frequency of every word in the messages of the class SPAM.
-	spamFrequency := wordFrequencyFrom(ex.Classes.Spam)
-	//calculate the probability map(matrix) for every word to be in the SPAM class.
-	spamProbabilities := spamFrequency.Probability(vocabulary)
-	//Create struct Analysis with all these parameters just calculated
+func analysisFrom(ex experiment.Experiment, p Pipeline, trainingSet TrainingSet, m Model, evaluate bool) Analysis {
+	classifier := Train(p, m, ex.Classes)
 	analysis := Analysis{
-		TrainingSet: TrainingSet{
-			MessageTotal: totalTrainingMessages,
-			Ham: Class{
-				MessageTotal:      len(ex.Classes.Ham),
-				PofC:              float64(len(ex.Classes.Ham)) / float64(totalTrainingMessages),
-				WordFrequency:     hamFrequency,
-				WordProbabilities: hamProbabilities,
-			},
-			Spam: Class{
-				MessageTotal:      len(ex.Classes.Spam),
-				PofC:              float64(len(ex.Classes.Spam)) / float64(totalTrainingMessages),
-				WordFrequency:     spamFrequency,
-				WordProbabilities: spamProbabilities,
-			},
-			Vocabulary: vocabulary,
-		},
+		Name:        p.Name,
+		Model:       m.Name,
+		Classifier:  classifier,
+		TrainingSet: trainingSet,
 	}
-	//Create
-	if useTextMessageAsTest {
-		analysis.FoundClass = analysis.TestTextMessage(ex)
+	if evaluate {
+		analysis.TestSet = Evaluate(classifier, ex.Test)
 	} else {
-		analysis.TestSet = analysis.TestTestData(ex.Test)
+		analysis.FoundClass = classifier.Predict(ex.TextMessage)
+		analysis.TextMessage = ex.TextMessage
 	}
 
 	return analysis
 }
 
+// Train creates a model with the settings of the pipeline and trains it on
+// classes, which are already preprocessed.
+func Train(p Pipeline, m Model, classes experiment.Classes) Classifier {
+	classifier := m.New()
+	if w, ok := classifier.(Weighted); ok && p.TFIDF != nil {
+		w.UseTFIDF(*p.TFIDF)
+	}
+	if s, ok := classifier.(Selective); ok && p.Selection != nil {
+		s.UseFeatureSelection(*p.Selection)
+	}
+	classifier.Train(classes)
+	return classifier
+}
+
+// TrainingSet counts the words of the classes of ex after preprocessing a copy
+// of it with the pipeline, keeping the vocabulary its selection chooses.
+func (p Pipeline) TrainingSet(ex experiment.Experiment) TrainingSet {
+	pex := ex.Copy()
+	for _, pre := range p.Preprocessors {
+		pre.Process(&pex)
+	}
+	var selection FeatureSelection
+	if p.Selection != nil {
+		selection = *p.Selection
+	}
+	return newTrainingSet(pex.Classes, selection)
+}
+
+func newTrainingSet(classes experiment.Classes, selection FeatureSelection) TrainingSet {
+	ts := countTrainingSet(classes)
+	//Drop the words the feature selection does not keep before calculating any probabilities
+	ts.Vocabulary = selection.Select(ts)
+	ts.selected = selection != (FeatureSelection{})
+	return ts
+}
+
+// countTrainingSet counts the messages and words of the classes, without
+// calculating any probabilities.
+func countTrainingSet(classes experiment.Classes) TrainingSet {
+	//Total amount of training messages i.e. the sum of the length of the two classes in experiments
+	totalTrainingMessages := len(classes.Ham) + len(classes.Spam)
+	return TrainingSet{
+		MessageTotal: totalTrainingMessages,
+		Ham: Class{
+			MessageTotal: len(classes.Ham),
+			PofC:         float64(len(classes.Ham)) / float64(totalTrainingMessages),
+			//Calculate the word frequency map I.E. the frequency of every word in the messages of the class HAM.
+			WordFrequency:     wordFrequencyFrom(classes.Ham),
+			DocumentFrequency: documentFrequencyFrom(classes.Ham),
+		},
+		Spam: Class{
+			MessageTotal: len(classes.Spam),
+			PofC:         float64(len(classes.Spam)) / float64(totalTrainingMessages),
+			//Calculate the word frequency map I.E. the frequency of every word in the messages of the class SPAM.
+			WordFrequency:     wordFrequencyFrom(classes.Spam),
+			DocumentFrequency: documentFrequencyFrom(classes.Spam),
+		},
+		//Make a vocabulary, i.e. a list of all the words
+		Vocabulary: vocabularyFrom(classes.Ham, classes.Spam),
+	}
+}
+
 func vocabularyFrom(messageLists ...[]string) Vocabulary {
 	keys := make(map[string]bool)
 	var vocabulary Vocabulary
 	for _, messageList := range messageLists {
 		for _, msg := range messageList {
-			for _, word := range strings.Split(msg, " ") {
-				if word == "" {
-					continue
-				}
+			for _, word := range words(msg) {
 				if _, exists := keys[word]; !exists {
 					keys[word] = true
 					vocabulary = append(vocabulary, word)
@@ -296,11 +375,7 @@ func wordFrequencyFrom(messageList []string) WordFrequency {
 	//Loop all the messages in class
 	for _, msg := range messageList {
 		//Loop over all the words in a message
-		for _, word := range strings.Split(msg, " ") {
-			if word == "" {
-				continue
-			}
-
+		for _, word := range words(msg) {
 			occurrences, exists := frequency[word]
 			//If the word exist in the map increase the frequency by one
 			if exists {
@@ -314,3 +389,22 @@ func wordFrequencyFrom(messageList []string) WordFrequency {
 
 	return frequency
 }
+
+func documentFrequencyFrom(messageList []string) DocumentFrequency {
+	frequency := make(DocumentFrequency)
+	for _, msg := range messageList {
+		seen := make(map[string]bool)
+		for _, word := range words(msg) {
+			if !seen[word] {
+				seen[word] = true
+				frequency[word]++
+			}
+		}
+	}
+	return frequency
+}
+
+// words splits a message into its words, the same way the Naive Bayes model does.
+func words(msg string) []string {
+	return bayes.Words(msg)
+}
@@ -0,0 +1,630 @@
+package analysis_test
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+)
+
+var classes = experiment.Classes{
+	Ham:  []string{"see you at lunch", "call me when you are home", "lunch is on me"},
+	Spam: []string{"win a free prize now", "call now to claim your prize", "free entry"},
+}
+
+func TestLearnMatchesTraining(t *testing.T) {
+	var batch analysis.NaiveBayes
+	batch.Train(classes)
+
+	var online analysis.NaiveBayes
+	online.Train(experiment.Classes{Ham: classes.Ham[:1], Spam: classes.Spam[:1]})
+	for _, msg := range classes.Ham[1:] {
+		if err := online.Learn(experiment.HamClass, msg); err != nil {
+			t.Fatalf("learning %q: %s", msg, err)
+		}
+	}
+	for _, msg := range classes.Spam[1:] {
+		if err := online.Learn(experiment.SpamClass, msg); err != nil {
+			t.Fatalf("learning %q: %s", msg, err)
+		}
+	}
+
+	if online.Model.VocabularySize() != batch.Model.VocabularySize() {
+		t.Errorf("vocabulary: expected %d words, got %d", batch.Model.VocabularySize(), online.Model.VocabularySize())
+	}
+	for _, text := range []string{"free prize", "see you at home", "call me now"} {
+		expected, got := batch.PredictProba(text), online.PredictProba(text)
+		if math.Abs(expected-got) > 1e-9 {
+			t.Errorf("spam probability of %q: expected %f, got %f", text, expected, got)
+		}
+	}
+}
+
+func TestUnlearn(t *testing.T) {
+	var nb analysis.NaiveBayes
+	nb.Train(classes)
+	before := nb.PredictProba("claim your free prize")
+
+	if err := nb.Learn(experiment.HamClass, "claim your free prize at lunch"); err != nil {
+		t.Fatalf("learning: %s", err)
+	}
+	if err := nb.Unlearn(experiment.HamClass, "claim your free prize at lunch"); err != nil {
+		t.Fatalf("unlearning: %s", err)
+	}
+	if after := nb.PredictProba("claim your free prize"); math.Abs(before-after) > 1e-9 {
+		t.Errorf("spam probability after unlearning: expected %f, got %f", before, after)
+	}
+	if err := nb.Unlearn(experiment.SpamClass, "never seen before"); err == nil {
+		t.Errorf("unlearning a message that was never learned: expected an error")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	ex := experiment.Experiment{
+		Classes: classes,
+		Test: experiment.TestSet{Cases: []experiment.TestCase{
+			{Class: experiment.SpamClass, Text: "free lunch now"},
+			{Class: experiment.HamClass, Text: "see you soon"},
+		}},
+	}
+	stats := analysis.Describe(ex, []analysis.Pipeline{{Name: "none"}}, 3)
+	if stats.Classes[0].Messages != 4 || stats.Classes[1].Messages != 4 {
+		t.Errorf("counting messages per class: expected 4 and 4, got %d and %d", stats.Classes[0].Messages, stats.Classes[1].Messages)
+	}
+	if stats.Classes[1].Words.Max != 6 || stats.Classes[1].Words.Min != 2 {
+		t.Errorf("spam message lengths: expected 2 to 6 words, got %+v", stats.Classes[1].Words)
+	}
+	if len(stats.Zipf.Top) != 3 || stats.Zipf.Top[0] != (analysis.WordCount{Word: "free", Count: 3}) || stats.Zipf.Tokens != 33 {
+		t.Errorf("most frequent words: got %+v", stats.Zipf)
+	}
+	p := stats.Pipelines[0]
+	if p.TestTokens != 6 || p.OutOfVocabulary != 1 {
+		t.Errorf("out of vocabulary words: expected 1 of 6, got %d of %d", p.OutOfVocabulary, p.TestTokens)
+	}
+
+	empty := analysis.Describe(experiment.Experiment{}, []analysis.Pipeline{{Name: "none"}}, 3)
+	if rate := empty.Zipf.HapaxRate(); rate != 0 {
+		t.Errorf("describing no messages: expected a hapax rate of 0, got %v", rate)
+	}
+}
+
+func TestLabelNoise(t *testing.T) {
+	var cases []experiment.TestCase
+	for i := 0; i < 10; i++ {
+		cases = append(cases,
+			experiment.TestCase{Class: experiment.HamClass, Text: "see you at lunch later", Line: 3 * i},
+			experiment.TestCase{Class: experiment.SpamClass, Text: "win a free cash prize now", Line: 3*i + 1})
+	}
+	cases = append(cases, experiment.TestCase{Class: experiment.HamClass, Text: "win a free cash prize", Line: 100, Source: "ham/inbox.mbox"})
+
+	models := analysis.DefaultModels()
+	suspects := analysis.LabelNoise(cases, analysis.Pipeline{}, models[0], 3, 1, 0.9)
+	if len(suspects) != 1 || suspects[0].Location() != "ham/inbox.mbox line 100" || suspects[0].Predicted != experiment.SpamClass {
+		t.Errorf("finding the mislabeled message: expected ham/inbox.mbox line 100, got %+v", suspects)
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	ex := experiment.Experiment{
+		Classes:     classes,
+		TextMessage: "claim your free prize",
+		Test: experiment.TestSet{Cases: []experiment.TestCase{
+			{Class: experiment.SpamClass, Text: "free prize now"},
+			{Class: experiment.HamClass, Text: "see you at lunch"},
+		}},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(evaluate bool) {
+			defer wg.Done()
+			for _, a := range analysis.Run(ex, analysis.Options{Evaluate: evaluate}) {
+				if evaluate && a.TestSet.MessageTotal != 2 {
+					t.Errorf("evaluating %s [%s]: expected 2 test messages, got %d", a.Name, a.Model, a.TestSet.MessageTotal)
+				}
+				if !evaluate && a.FoundClass != experiment.SpamClass {
+					t.Errorf("classifying with %s [%s]: expected spam, got %s", a.Name, a.Model, a.FoundClass)
+				}
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	if ex.Classes.Ham[0] != "see you at lunch" || ex.Test.Cases[0].Text != "free prize now" {
+		t.Errorf("running: the experiment was changed")
+	}
+}
+
+func pairs(both, firstOnly, secondOnly, neither int) ([]bool, []bool) {
+	var first, second []bool
+	add := func(n int, a, b bool) {
+		for i := 0; i < n; i++ {
+			first, second = append(first, a), append(second, b)
+		}
+	}
+	add(both, true, true)
+	add(firstOnly, true, false)
+	add(secondOnly, false, true)
+	add(neither, false, false)
+	return first, second
+}
+
+func TestMcNemar(t *testing.T) {
+	m := analysis.McNemarTest(pairs(50, 10, 0, 5))
+	if !m.Exact || math.Abs(m.PValue-2/1024.) > 1e-9 {
+		t.Errorf("testing 10 against 0 disagreements: expected exact p 0.00195, got %+v", m)
+	}
+	m = analysis.McNemarTest(pairs(500, 30, 10, 5))
+	if m.Exact || math.Abs(m.Statistic-9.025) > 1e-9 || math.Abs(m.PValue-.00266) > 1e-4 {
+		t.Errorf("testing 30 against 10 disagreements: expected chi-squared 9.025 and p 0.00266, got %+v", m)
+	}
+	if m := analysis.McNemarTest(pairs(10, 0, 0, 2)); m.PValue != 1 {
+		t.Errorf("testing without disagreements: expected p 1, got %f", m.PValue)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	first, second := pairs(80, 0, 20, 0)
+	i := analysis.Bootstrap(first, second, 500, 1)
+	if math.Abs(i.Difference-.2) > 1e-9 || i.Lower <= 0 || i.Upper < i.Difference || !i.Excludes(0) {
+		t.Errorf("bootstrapping a 20%% improvement: expected an interval around .2 above 0, got %+v", i)
+	}
+	same := analysis.Bootstrap(first, first, 500, 1)
+	if same.Lower != 0 || same.Upper != 0 {
+		t.Errorf("bootstrapping equal results: expected the interval [0, 0], got %+v", same)
+	}
+}
+
+func TestComparePipelines(t *testing.T) {
+	baseline, better := pairs(80, 0, 20, 0)
+	testSet := func(correct []bool) analysis.TestSet {
+		var t analysis.TestSet
+		for i, c := range correct {
+			p := analysis.Prediction{Case: i, Line: i + 1, Class: experiment.SpamClass, Predicted: experiment.SpamClass}
+			if !c {
+				p.Predicted = experiment.HamClass
+			}
+			t.Predictions = append(t.Predictions, p)
+		}
+		return t
+	}
+	analyses := analysis.Analyses{
+		{Name: "Default", Model: "Naive Bayes", TestSet: testSet(baseline)},
+		{Name: "Stemmer", Model: "Naive Bayes", TestSet: testSet(better)},
+		{Name: "Stemmer", Model: "Logistic Regression", TestSet: testSet(better)},
+	}
+	comparisons, err := analysis.ComparePipelines(analyses, "", 200, 1)
+	if err != nil || len(comparisons) != 1 {
+		t.Fatalf("comparing pipelines: expected 1 comparison, got %v, %v", comparisons, err)
+	}
+	if c := comparisons[0]; c.Baseline != "Default" || c.Pipeline != "Stemmer" || !c.Significant() {
+		t.Errorf("comparing pipelines: expected Stemmer to be significantly better, got %+v", c)
+	}
+	if _, err := analysis.ComparePipelines(analyses, "Missing", 200, 1); err == nil {
+		t.Errorf("comparing with a missing baseline: expected an error")
+	}
+
+	// the same number of cases, but from another split
+	other := testSet(better)
+	other.Predictions[0].Line = 1000
+	analyses[1].TestSet = other
+	_, err = analysis.ComparePipelines(analyses, "", 200, 1)
+	if err == nil || !strings.Contains(err.Error(), "comparing Stemmer with Default: test case 1 is line 1000 of the data in one and line 1 in the other") {
+		t.Errorf("comparing pipelines tested on different cases: expected an error naming Stemmer first, got %v", err)
+	}
+	analyses[1].TestSet.Predictions = other.Predictions[:50]
+	_, err = analysis.ComparePipelines(analyses, "", 200, 1)
+	if err == nil || !strings.Contains(err.Error(), "comparing Stemmer with Default: tested on 50 and 100 cases") {
+		t.Errorf("comparing pipelines tested on fewer cases: expected an error naming Stemmer first, got %v", err)
+	}
+}
+
+func TestMisclassified(t *testing.T) {
+	ex := experiment.Experiment{Classes: classes, Test: experiment.TestSet{Cases: []experiment.TestCase{
+		{Class: experiment.HamClass, Text: "free prize at lunch", Line: 1},
+		{Class: experiment.SpamClass, Text: "see you at home", Line: 2, Source: "spam/inbox.mbox"},
+		{Class: experiment.SpamClass, Text: "claim your free prize", Line: 3},
+	}}}
+	analyses := analysis.Run(ex, analysis.Options{Pipelines: analysis.DefaultPipelines()[:1], Models: analysis.DefaultModels()[:1], Evaluate: true})
+	mistakes := analysis.Misclassified(ex, analyses, analysis.MisclassificationFilter{Words: 2})
+	if len(mistakes) != 2 {
+		t.Fatalf("listing mistakes: expected 2, got %d", len(mistakes))
+	}
+	for _, m := range mistakes {
+		if m.Line == 1 && (m.Mistake != analysis.FalsePositive || len(m.Words) == 0 || m.Words[0].LogOdds <= 0) {
+			t.Errorf("listing mistakes: expected line 1 to be a false positive pushed by spam words, got %+v", m)
+		}
+		if m.Line == 2 && (m.Mistake != analysis.FalseNegative || m.Location() != "spam/inbox.mbox line 2") {
+			t.Errorf("listing mistakes: expected line 2 of spam/inbox.mbox to be a false negative, got %+v", m)
+		}
+		if m.Confidence() < .5 {
+			t.Errorf("listing mistakes: expected a confidence of at least .5, got %f", m.Confidence())
+		}
+	}
+	filtered := analysis.Misclassified(ex, analyses, analysis.MisclassificationFilter{Mistake: analysis.FalseNegative, Contains: "HOME"})
+	if len(filtered) != 1 || filtered[0].Original != "see you at home" {
+		t.Errorf("filtering mistakes: expected the false negative about home, got %+v", filtered)
+	}
+}
+
+func TestLearningCurve(t *testing.T) {
+	ex := experiment.Experiment{Classes: classes, Test: experiment.TestSet{Cases: []experiment.TestCase{
+		{Class: experiment.HamClass, Text: "lunch at home"},
+		{Class: experiment.SpamClass, Text: "claim a free prize"},
+	}}}
+	points := analysis.LearningCurve(ex, analysis.DefaultPipelines()[0], analysis.DefaultModels()[0], []float64{.3, .7, 1}, 1)
+	if len(points) != 3 {
+		t.Fatalf("learning curve: expected 3 points, got %d", len(points))
+	}
+	expected := []int{2, 4, 6}
+	for i, p := range points {
+		if p.TrainingMessages != expected[i] {
+			t.Errorf("learning curve at %.1f: expected %d training messages, got %d", p.Fraction, expected[i], p.TrainingMessages)
+		}
+	}
+	if last := points[2]; last.TrainAccuracy != 1 || last.TestAccuracy != 1 {
+		t.Errorf("learning curve on all messages: expected perfect accuracy, got %+v", last)
+	}
+	ex.Test.Cases = nil
+	for _, p := range analysis.LearningCurve(ex, analysis.DefaultPipelines()[0], analysis.DefaultModels()[0], []float64{.5, 1}, 1) {
+		if p.TestAccuracy != 0 {
+			t.Errorf("learning curve without test messages: expected a test accuracy of 0, got %+v", p)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	space := analysis.SearchSpace{
+		Pipelines: analysis.DefaultPipelines()[:2],
+		Alphas:    []float64{.5, 1},
+		NGrams:    []int{1, 2},
+		Priors:    []analysis.Prior{analysis.UniformPrior, analysis.ClassPrior},
+	}
+	grid := space.Grid()
+	if len(grid) != 16 {
+		t.Fatalf("grid: expected 16 combinations, got %d", len(grid))
+	}
+	random := space.Random(5, 1)
+	seen := make(map[string]bool)
+	for _, c := range random {
+		seen[c.String()] = true
+	}
+	if len(random) != 5 || len(seen) != 5 {
+		t.Errorf("random search: expected 5 different combinations, got %d of %d", len(seen), len(random))
+	}
+
+	var cases []experiment.TestCase
+	for _, msg := range classes.Ham {
+		cases = append(cases, experiment.TestCase{Class: experiment.HamClass, Text: msg})
+	}
+	for _, msg := range classes.Spam {
+		cases = append(cases, experiment.TestCase{Class: experiment.SpamClass, Text: msg})
+	}
+	results := analysis.Search(cases, grid, 3, 1)
+	if len(results) != len(grid) {
+		t.Fatalf("search: expected a result for every combination, got %d", len(results))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Accuracy > results[i-1].Accuracy {
+			t.Errorf("search: expected the results best first, got %f after %f", results[i].Accuracy, results[i-1].Accuracy)
+		}
+	}
+}
+
+func TestNaiveBayesSmoothingAndPrior(t *testing.T) {
+	add1 := analysis.NaiveBayes{}
+	add1.Train(classes)
+	sharp := analysis.NaiveBayes{Alpha: .01}
+	sharp.Train(classes)
+	if a, b := add1.PredictProba("free prize"), sharp.PredictProba("free prize"); b <= a {
+		t.Errorf("smoothing: expected less smoothing to be surer of spam, got %f and %f", a, b)
+	}
+	unbalanced := experiment.Classes{Ham: append(append([]string(nil), classes.Ham...), "home", "home again"), Spam: classes.Spam}
+	uniform := analysis.NaiveBayes{}
+	uniform.Train(unbalanced)
+	prior := analysis.NaiveBayes{Prior: analysis.ClassPrior}
+	prior.Train(unbalanced)
+	if a, b := uniform.PredictProba("call"), prior.PredictProba("call"); b >= a {
+		t.Errorf("class prior with more ham: expected a lower spam probability, got %f and %f", a, b)
+	}
+}
+
+func TestSynthesize(t *testing.T) {
+	ex := experiment.Experiment{Classes: classes, Test: experiment.TestSet{Cases: []experiment.TestCase{
+		{Class: experiment.SpamClass, Text: "claim a free prize"},
+	}}}
+	synthetic := analysis.Synthesize(ex, 3, 1)
+	if len(synthetic.Classes.Ham) != 9 || len(synthetic.Classes.Spam) != 9 || len(synthetic.Test.Cases) != 3 {
+		t.Fatalf("synthesizing 3 times: expected 9 ham, 9 spam and 3 test cases, got %d, %d and %d",
+			len(synthetic.Classes.Ham), len(synthetic.Classes.Spam), len(synthetic.Test.Cases))
+	}
+	for _, tc := range synthetic.Test.Cases {
+		if tc.Class != experiment.SpamClass || tc.Text == "" {
+			t.Errorf("synthesizing test cases: expected spam messages, got %+v", tc)
+		}
+	}
+	m := analysis.Measure(synthetic, analysis.DefaultPipelines()[0], analysis.DefaultModels()[0])
+	if m.TrainingMessages != 18 || m.Classified != 3 || m.Training <= 0 || m.Allocated == 0 {
+		t.Errorf("measuring: expected 18 training and 3 test messages with time and memory, got %+v", m)
+	}
+}
+
+func TestModels(t *testing.T) {
+	for _, m := range analysis.DefaultModels() {
+		classifier := m.New()
+		classifier.Train(classes)
+		for text, expected := range map[string]experiment.Class{
+			"claim a free prize now": experiment.SpamClass,
+			"see you at lunch":       experiment.HamClass,
+		} {
+			if class := classifier.Predict(text); class != expected {
+				t.Errorf("classifying %q with %s: expected %s, got %s", text, m.Name, expected, class)
+			}
+			if p := classifier.PredictProba(text); (p > .5) != (expected == experiment.SpamClass) {
+				t.Errorf("spam probability of %q with %s: expected it to agree with %s, got %f", text, m.Name, expected, p)
+			}
+		}
+		if named, err := analysis.ModelNamed(strings.ReplaceAll(strings.ToLower(m.Name), " ", "-")); err != nil || named.Name != m.Name {
+			t.Errorf("finding model %s by name: got %+v, %v", m.Name, named, err)
+		}
+	}
+	if _, err := analysis.ModelNamed("perceptron"); err == nil {
+		t.Errorf("finding an unknown model: expected an error")
+	}
+}
+
+func TestEvaluateEmpty(t *testing.T) {
+	var nb analysis.NaiveBayes
+	nb.Train(classes)
+	results := analysis.Evaluate(&nb, experiment.TestSet{})
+	if results.Accuracy() != 0 || results.PercentageCorrectHam != 0 || results.PercentageCorrectSpam != 0 {
+		t.Errorf("evaluating without test cases: expected 0 for every share, got %+v and accuracy %f", results, results.Accuracy())
+	}
+}
+
+func TestLogisticRegression(t *testing.T) {
+	a, b := analysis.LogisticRegression{Seed: 3}, analysis.LogisticRegression{Seed: 3}
+	a.Train(classes)
+	b.Train(classes)
+	if pa, pb := a.PredictProba("free prize"), b.PredictProba("free prize"); pa != pb {
+		t.Errorf("training with the same seed: expected the same spam probability, got %f and %f", pa, pb)
+	}
+	if p := a.PredictProba("free prize"); p <= .5 {
+		t.Errorf("spam probability of spam words: expected above .5, got %f", p)
+	}
+
+	regularized := analysis.LogisticRegression{Seed: 3, Lambda: 1}
+	regularized.Train(classes)
+	if p, q := a.PredictProba("free prize"), regularized.PredictProba("free prize"); math.Abs(q-.5) >= math.Abs(p-.5) {
+		t.Errorf("strong L2 penalty: expected a spam probability closer to .5, got %f and %f", p, q)
+	}
+	longer := analysis.LogisticRegression{Seed: 3, Epochs: 100}
+	longer.Train(classes)
+	if p, q := a.PredictProba("free prize"), longer.PredictProba("free prize"); q <= p {
+		t.Errorf("training for more epochs: expected to be surer of spam, got %f and %f", p, q)
+	}
+	for _, lambda := range []float64{10, 1000} {
+		huge := analysis.LogisticRegression{Seed: 3, Lambda: lambda, Epochs: 1000}
+		huge.Train(classes)
+		if p := huge.PredictProba("free prize"); math.IsNaN(p) || math.IsInf(p, 0) {
+			t.Errorf("learning rate times lambda %g: expected a spam probability, got %f", .1*lambda, p)
+		}
+	}
+}
+
+func TestTFIDF(t *testing.T) {
+	corpus := []string{"free prize", "free lunch", "see you"}
+	plain := analysis.NewTFIDF(analysis.TFIDFOptions{}, corpus)
+	if idf := plain.IDF("free"); math.Abs(idf-(math.Log(3./2)+1)) > 1e-9 {
+		t.Errorf("idf of a word in 2 of 3 messages: expected %f, got %f", math.Log(3./2)+1, idf)
+	}
+	if idf := plain.IDF("unknown"); idf != 0 {
+		t.Errorf("idf of an unknown word: expected 0, got %f", idf)
+	}
+	if w := plain.Weights("free free prize")["free"]; math.Abs(w-2*plain.IDF("free")) > 1e-9 {
+		t.Errorf("weight of a repeated word: expected twice its idf, got %f", w)
+	}
+
+	smooth := analysis.NewTFIDF(analysis.TFIDFOptions{Smooth: true, Sublinear: true}, corpus)
+	if idf := smooth.IDF("free"); math.Abs(idf-(math.Log(4./3)+1)) > 1e-9 {
+		t.Errorf("smoothed idf: expected %f, got %f", math.Log(4./3)+1, idf)
+	}
+	if idf := smooth.IDF("unknown"); math.Abs(idf-(math.Log(4)+1)) > 1e-9 {
+		t.Errorf("smoothed idf of an unknown word: expected %f, got %f", math.Log(4)+1, idf)
+	}
+	if w := smooth.Weights("free free prize")["free"]; math.Abs(w-(1+math.Log(2))*smooth.IDF("free")) > 1e-9 {
+		t.Errorf("sublinear weight of a repeated word: expected %f, got %f", (1+math.Log(2))*smooth.IDF("free"), w)
+	}
+
+	ex := experiment.Experiment{Classes: classes, TextMessage: "claim your free prize"}
+	tfidf := analysis.Pipeline{Name: "TF-IDF", TFIDF: &analysis.TFIDFOptions{Sublinear: true, Smooth: true}}
+	for _, a := range analysis.Run(ex, analysis.Options{Pipelines: []analysis.Pipeline{tfidf}}) {
+		if a.FoundClass != experiment.SpamClass {
+			t.Errorf("classifying with TF-IDF [%s]: expected spam, got %s", a.Model, a.FoundClass)
+		}
+		if nb, ok := a.Classifier.(*analysis.NaiveBayes); ok && nb.TFIDF == nil {
+			t.Errorf("training Naive Bayes on the TF-IDF pipeline: expected TF-IDF weights")
+		}
+	}
+}
+
+func TestExplainPreprocessed(t *testing.T) {
+	ex := experiment.Experiment{Classes: classes, TextMessage: "Winning prizes, claim now!"}
+	stemmer := analysis.DefaultPipelines()[2]
+	for _, a := range analysis.Run(ex, analysis.Options{Pipelines: []analysis.Pipeline{stemmer}, Models: analysis.DefaultModels()[:1]}) {
+		e := a.Classifier.(analysis.Explainer).Explain(a.TextMessage)
+		stemmed := false
+		for _, w := range e.Words {
+			if w.Word == "Winning" {
+				t.Errorf("explaining on the %s: expected stemmed words, got %q", a.Name, w.Word)
+			}
+			stemmed = stemmed || w.Word == "win"
+		}
+		if !stemmed || e.Class != a.FoundClass {
+			t.Errorf("explaining on the %s: expected the stem win and the class %s, got %+v", a.Name, a.FoundClass, e)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	var nb analysis.NaiveBayes
+	nb.Train(classes)
+	e := nb.Explain("claim your free prize tomorrow")
+	if e.Class != experiment.SpamClass || e.Class != nb.Predict(e.Text) {
+		t.Errorf("explaining: expected the class spam, got %s", e.Class)
+	}
+	if p := 1 / (1 + math.Exp(-e.LogOdds)); math.Abs(p-e.SpamProbability) > 1e-9 {
+		t.Errorf("explaining: expected log odds %f to give the spam probability %f, got %f", e.LogOdds, e.SpamProbability, p)
+	}
+	var sum float64
+	for i, w := range e.Words {
+		sum += w.LogOdds
+		if w.InVocabulary && math.Abs(w.LogOdds-w.Weight*(w.LogPSpam-w.LogPHam)) > 1e-9 {
+			t.Errorf("contribution of %q: expected weight times the difference in log probability, got %+v", w.Word, w)
+		}
+		if i > 0 && w.InVocabulary && math.Abs(w.LogOdds) > math.Abs(e.Words[i-1].LogOdds) {
+			t.Errorf("contribution of %q: expected the words most influential first", w.Word)
+		}
+	}
+	if math.Abs(sum-e.LogOdds) > 1e-9 {
+		t.Errorf("explaining: expected the contributions to add up to %f, got %f", e.LogOdds, sum)
+	}
+	if last := e.Words[len(e.Words)-1]; last.Word != "tomorrow" || last.InVocabulary || last.LogOdds != 0 {
+		t.Errorf("explaining an unknown word: expected it last without contribution, got %+v", last)
+	}
+}
+
+func TestDiscriminativeWords(t *testing.T) {
+	ts := analysis.DefaultPipelines()[0].TrainingSet(experiment.Experiment{Classes: classes})
+	spamWords := map[string]bool{"win": true, "a": true, "free": true, "prize": true, "now": true, "to": true, "claim": true, "your": true, "entry": true}
+	for _, name := range []string{"llr", "chi2", "mi"} {
+		ranking, err := analysis.RankingType(name)
+		if err != nil {
+			t.Fatalf("parsing ranking %s: %s", name, err)
+		}
+		for _, class := range []experiment.Class{experiment.HamClass, experiment.SpamClass} {
+			scores := ts.DiscriminativeWords(class, 3, ranking)
+			if len(scores) != 3 {
+				t.Fatalf("top 3 %s words by %s: got %d", class, ranking, len(scores))
+			}
+			for i, ws := range scores {
+				if spamWords[ws.Word] != (class == experiment.SpamClass) {
+					t.Errorf("top %s words by %s: did not expect %q", class, ranking, ws.Word)
+				}
+				if ws.Score <= 0 || i > 0 && ws.Score > scores[i-1].Score {
+					t.Errorf("top %s words by %s: expected positive scores, highest first, got %+v", class, ranking, scores)
+				}
+			}
+		}
+	}
+	if top := ts.DiscriminativeWords(experiment.SpamClass, 1, analysis.LogLikelihoodRatio); top[0].Word != "free" && top[0].Word != "prize" {
+		t.Errorf("most discriminative spam word: expected free or prize, got %q", top[0].Word)
+	}
+	if none := ts.DiscriminativeWords(experiment.SpamClass, -1, analysis.LogLikelihoodRatio); len(none) != 0 {
+		t.Errorf("top -1 spam words: expected none, got %+v", none)
+	}
+	if _, err := analysis.RankingType("frequency"); err == nil {
+		t.Errorf("parsing an unknown ranking: expected an error")
+	}
+}
+
+func TestFeatureSelection(t *testing.T) {
+	var all analysis.NaiveBayes
+	all.Train(classes)
+	frequent := analysis.NaiveBayes{Selection: analysis.FeatureSelection{MinDocumentFrequency: 2}}
+	frequent.Train(classes)
+	if len(frequent.TrainingSet.Vocabulary) != 7 {
+		t.Errorf("minimum document frequency 2: expected the 7 words in two messages, got %v", frequent.TrainingSet.Vocabulary)
+	}
+	for _, ranking := range []analysis.Ranking{analysis.LogLikelihoodRatio, analysis.ChiSquare, analysis.MutualInformation} {
+		capped := analysis.NaiveBayes{Selection: analysis.FeatureSelection{MaxVocabulary: 3, Ranking: ranking}}
+		capped.Train(classes)
+		if len(capped.TrainingSet.Vocabulary) != 3 {
+			t.Errorf("vocabulary capped at 3 by %s: got %v", ranking, capped.TrainingSet.Vocabulary)
+		}
+		if _, exists := capped.Model.Probability(bayes.HamClass, "is"); exists || capped.Model.VocabularySize() != 3 {
+			t.Errorf("vocabulary capped at 3 by %s: did not expect a probability for a word in one message", ranking)
+		}
+	}
+
+	ex := experiment.Experiment{Classes: classes, Test: experiment.TestSet{Cases: []experiment.TestCase{
+		{Class: experiment.HamClass, Text: "lunch at home"},
+		{Class: experiment.SpamClass, Text: "claim a free prize"},
+	}}}
+	points := analysis.VocabularyCurve(ex, analysis.DefaultPipelines()[0], analysis.DefaultModels()[0], analysis.FeatureSelection{}, []int{2, 5, 1000})
+	if len(points) != 3 {
+		t.Fatalf("vocabulary curve: expected 3 points, got %d", len(points))
+	}
+	for i, size := range []int{2, 5, len(all.TrainingSet.Vocabulary)} {
+		if points[i].VocabularySize != size || points[i].Accuracy < 0 || points[i].Accuracy > 1 {
+			t.Errorf("vocabulary curve at %d words: expected %d words, got %+v", points[i].MaxVocabulary, size, points[i])
+		}
+	}
+	if points[2].Accuracy != 1 {
+		t.Errorf("vocabulary curve with every word: expected perfect accuracy, got %f", points[2].Accuracy)
+	}
+}
+
+func TestLearnRespectsSelection(t *testing.T) {
+	nb := analysis.NaiveBayes{Selection: analysis.FeatureSelection{MaxVocabulary: 3}}
+	nb.Train(classes)
+	before := nb.PredictProba("cheap pills")
+	if err := nb.Learn(experiment.SpamClass, "cheap pills"); err != nil {
+		t.Fatalf("learning: %s", err)
+	}
+	if nb.Model.VocabularySize() != 3 || nb.Model.Messages(bayes.SpamClass) != 4 {
+		t.Errorf("learning new words with a capped vocabulary: expected 3 words and 4 spam messages, got %d and %d",
+			nb.Model.VocabularySize(), nb.Model.Messages(bayes.SpamClass))
+	}
+	if after := nb.PredictProba("cheap pills"); after != before {
+		t.Errorf("learning words left out of the vocabulary: expected the spam probability to stay %f, got %f", before, after)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	var records []parse.Record
+	for i := 0; i < 20; i++ {
+		for _, msg := range classes.Ham {
+			records = append(records, parse.Record{Line: len(records) + 1, Class: experiment.HamClass, Text: fmt.Sprintf("%s %d", msg, i)})
+		}
+		for _, msg := range classes.Spam {
+			records = append(records, parse.Record{Line: len(records) + 1, Class: experiment.SpamClass, Text: fmt.Sprintf("%s %d", msg, i)})
+		}
+	}
+	scan := func(fn func(parse.Record) error) error {
+		for _, r := range records {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	selected := analysis.Pipeline{Name: "Selected", Selection: &analysis.FeatureSelection{MaxVocabulary: 3, Ranking: analysis.ChiSquare}}
+	tfidf := analysis.DefaultPipelines()[5]
+	analyses, err := analysis.RunStream(scan, analysis.StreamOptions{
+		Pipelines:  []analysis.Pipeline{selected, tfidf},
+		TrainRatio: .75,
+		TestSample: 5,
+		Seed:       1,
+	})
+	if err != nil {
+		t.Fatalf("streaming: %s", err)
+	}
+	if len(analyses) != 1 || analyses[0].Name != "Selected" || analyses[0].Model != "Naive Bayes" {
+		t.Fatalf("streaming: expected Naive Bayes on the pipeline without TF-IDF only, got %d analyses", len(analyses))
+	}
+	if size := analyses[0].Classifier.(*analysis.NaiveBayes).Model.VocabularySize(); size != 3 {
+		t.Errorf("streaming with the selection of the pipeline: expected 3 words, got %d", size)
+	}
+	if total := analyses[0].TestSet.MessageTotal; total != 5 {
+		t.Errorf("streaming with a test sample of 5: expected 5 test messages, got %d", total)
+	}
+	if tfidf.Streamable() || analysis.DefaultModels()[1].Streamable() || !analysis.DefaultModels()[0].Streamable() {
+		t.Errorf("streamable: expected TF-IDF and logistic regression not to be")
+	}
+}
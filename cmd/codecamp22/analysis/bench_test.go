@@ -0,0 +1,98 @@
+package analysis_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+)
+
+var (
+	benchOnce       sync.Once
+	benchExperiment experiment.Experiment
+	benchErr        error
+)
+
+// benchData is trainingData.data split with a fixed seed, loaded once for
+// all benchmarks.
+func benchData(b *testing.B) experiment.Experiment {
+	benchOnce.Do(func() {
+		benchExperiment, _, benchErr = parse.LoadFile("../trainingData.data", parse.Options{Split: true, Seed: 1})
+	})
+	if benchErr != nil {
+		b.Skipf("loading trainingData.data: %s", benchErr)
+	}
+	return benchExperiment
+}
+
+func benchmarkTrain(b *testing.B, ex experiment.Experiment) {
+	for _, p := range analysis.DefaultPipelines() {
+		for _, m := range analysis.DefaultModels() {
+			b.Run(p.Name+"/"+m.Name, func(b *testing.B) {
+				pex := ex.Copy()
+				for _, pre := range p.Preprocessors {
+					pre.Process(&pex)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				start := time.Now()
+				for i := 0; i < b.N; i++ {
+					analysis.Train(p, m, pex.Classes)
+				}
+				b.ReportMetric(float64(b.N*(len(pex.Classes.Ham)+len(pex.Classes.Spam)))/time.Since(start).Seconds(), "msgs/s")
+			})
+		}
+	}
+}
+
+func BenchmarkTrain(b *testing.B) {
+	benchmarkTrain(b, benchData(b))
+}
+
+func BenchmarkTrainSynthetic4x(b *testing.B) {
+	benchmarkTrain(b, analysis.Synthesize(benchData(b), 4, 1))
+}
+
+func BenchmarkPredict(b *testing.B) {
+	ex := benchData(b)
+	for _, p := range analysis.DefaultPipelines() {
+		for _, m := range analysis.DefaultModels() {
+			b.Run(p.Name+"/"+m.Name, func(b *testing.B) {
+				pex := ex.Copy()
+				for _, pre := range p.Preprocessors {
+					pre.Process(&pex)
+				}
+				c := analysis.Train(p, m, pex.Classes)
+				cases := pex.Test.Cases
+				b.ReportAllocs()
+				b.ResetTimer()
+				start := time.Now()
+				for i := 0; i < b.N; i++ {
+					c.Predict(cases[i%len(cases)].Text)
+				}
+				b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "msgs/s")
+			})
+		}
+	}
+}
+
+func BenchmarkPreprocess(b *testing.B) {
+	ex := benchData(b)
+	for _, p := range analysis.DefaultPipelines() {
+		if len(p.Preprocessors) == 0 {
+			continue
+		}
+		b.Run(p.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pex := ex.Copy()
+				for _, pre := range p.Preprocessors {
+					pre.Process(&pex)
+				}
+			}
+		})
+	}
+}
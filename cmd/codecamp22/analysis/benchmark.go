@@ -0,0 +1,176 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Measurement is how long a model takes to train on a pipeline and to
+// classify, and how much memory training takes.
+type Measurement struct {
+	Pipeline string
+	Model    string
+	// TrainingMessages were preprocessed and trained on in Training
+	TrainingMessages int
+	Training         time.Duration
+	// Allocated is the memory allocated while training, Retained the memory
+	// the trained model still holds
+	Allocated uint64
+	Retained  uint64
+	// Classified test cases were preprocessed and classified in Classifying
+	Classified  int
+	Classifying time.Duration
+}
+
+// MessagesPerSecond is the classification throughput.
+func (m Measurement) MessagesPerSecond() float64 {
+	if m.Classifying <= 0 {
+		return 0
+	}
+	return float64(m.Classified) / m.Classifying.Seconds()
+}
+
+// Measure preprocesses the experiment with the pipeline, trains the model on
+// it and classifies every test case, timing both. The garbage collector runs
+// before and after training so the memory figures are about the model alone,
+// so measurements should not run concurrently.
+func Measure(ex experiment.Experiment, p Pipeline, m Model) Measurement {
+	measurement := Measurement{
+		Pipeline:         p.Name,
+		Model:            m.Name,
+		TrainingMessages: len(ex.Classes.Ham) + len(ex.Classes.Spam),
+		Classified:       len(ex.Test.Cases),
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	pex := ex.Copy()
+	pex.Test.Cases = nil
+	for _, pre := range p.Preprocessors {
+		pre.Process(&pex)
+	}
+	classifier := Train(p, m, pex.Classes)
+	measurement.Training = time.Since(start)
+
+	runtime.ReadMemStats(&after)
+	measurement.Allocated = after.TotalAlloc - before.TotalAlloc
+	pex = experiment.Experiment{}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		measurement.Retained = after.HeapAlloc - before.HeapAlloc
+	}
+
+	//Test cases are preprocessed one at a time, like messages to classify
+	//arrive, when every preprocessor can do that
+	test := experiment.Experiment{Test: experiment.TestSet{Cases: append([]experiment.TestCase(nil), ex.Test.Cases...)}}
+	start = time.Now()
+	if p.Streamable() {
+		for _, tc := range test.Test.Cases {
+			classifier.Predict(p.processMessage(tc.Text))
+		}
+	} else {
+		for _, pre := range p.Preprocessors {
+			pre.Process(&test)
+		}
+		for _, tc := range test.Test.Cases {
+			classifier.Predict(tc.Text)
+		}
+	}
+	measurement.Classifying = time.Since(start)
+	runtime.KeepAlive(classifier)
+	return measurement
+}
+
+// Synthesize returns an experiment factor times the size of ex, with
+// messages made up from the words of each class. Every message takes the
+// length of a random message of its class and draws its words from all the
+// words of the class, so common words stay common. New words are made up as
+// often as words occur only once in the class, so the vocabulary keeps
+// growing with the corpus as it does in real messages.
+func Synthesize(ex experiment.Experiment, factor int, seed int64) experiment.Experiment {
+	rng := rand.New(rand.NewSource(seed))
+	ham, spam := ex.Classes.Ham, ex.Classes.Spam
+	for _, tc := range ex.Test.Cases {
+		if tc.Class == experiment.SpamClass {
+			spam = append(spam, tc.Text)
+		} else {
+			ham = append(ham, tc.Text)
+		}
+	}
+	generators := map[experiment.Class]*generator{
+		experiment.HamClass:  newGenerator(experiment.HamClass, ham, rng),
+		experiment.SpamClass: newGenerator(experiment.SpamClass, spam, rng),
+	}
+
+	var out experiment.Experiment
+	for i := 0; i < factor*len(ex.Classes.Ham); i++ {
+		out.Classes.Ham = append(out.Classes.Ham, generators[experiment.HamClass].message())
+	}
+	for i := 0; i < factor*len(ex.Classes.Spam); i++ {
+		out.Classes.Spam = append(out.Classes.Spam, generators[experiment.SpamClass].message())
+	}
+	for i := 0; i < factor*len(ex.Test.Cases); i++ {
+		class := ex.Test.Cases[i%len(ex.Test.Cases)].Class
+		out.Test.Cases = append(out.Test.Cases, experiment.TestCase{Class: class, Text: generators[class].message()})
+	}
+	return out
+}
+
+// generator makes up messages from the words of the messages of a class.
+type generator struct {
+	class   experiment.Class
+	rng     *rand.Rand
+	lengths []int
+	words   []string
+	// novelty is the share of words that occur only once
+	novelty float64
+	made    int
+}
+
+func newGenerator(class experiment.Class, messages []string, rng *rand.Rand) *generator {
+	g := &generator{class: class, rng: rng}
+	for _, msg := range messages {
+		words := words(msg)
+		if len(words) == 0 {
+			continue
+		}
+		g.lengths = append(g.lengths, len(words))
+		g.words = append(g.words, words...)
+	}
+	frequency := wordFrequencyFrom(messages)
+	hapax := 0
+	for _, count := range frequency {
+		if count == 1 {
+			hapax++
+		}
+	}
+	if len(g.words) > 0 {
+		g.novelty = float64(hapax) / float64(len(g.words))
+	}
+	return g
+}
+
+func (g *generator) message() string {
+	if len(g.words) == 0 {
+		return ""
+	}
+	length := g.lengths[g.rng.Intn(len(g.lengths))]
+	words := make([]string, length)
+	for i := range words {
+		if g.rng.Float64() < g.novelty {
+			g.made++
+			words[i] = fmt.Sprintf("%s%d", g.class, g.made)
+			continue
+		}
+		words[i] = g.words[g.rng.Intn(len(g.words))]
+	}
+	return strings.Join(words, " ")
+}
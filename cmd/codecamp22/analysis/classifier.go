@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Classifier is a model that is trained on the ham and spam messages of an
+// experiment and then used to classify new messages.
+type Classifier interface {
+	// Train fits the model to the labeled training messages.
+	Train(classes experiment.Classes)
+	// Predict returns the class the message most likely belongs to.
+	Predict(text string) experiment.Class
+	// PredictProba returns the probability that the message is spam.
+	PredictProba(text string) float64
+}
+
+// Scorer is implemented by classifiers that can return both the class of a
+// message and its spam probability from one pass over the message.
+type Scorer interface {
+	Score(text string) (experiment.Class, float64)
+}
+
+// score classifies the message with c, in one pass when c is a Scorer.
+func score(c Classifier, text string) (experiment.Class, float64) {
+	if s, ok := c.(Scorer); ok {
+		return s.Score(text)
+	}
+	return c.Predict(text), c.PredictProba(text)
+}
+
+// Model names a classifier and knows how to create a fresh, untrained
+// instance of it, so every pipeline gets its own copy.
+type Model struct {
+	Name string
+	New  func() Classifier
+}
+
+// DefaultModels returns the models Run trains on every pipeline.
+func DefaultModels() []Model {
+	return []Model{
+		{Name: "Naive Bayes", New: func() Classifier { return &NaiveBayes{} }},
+		{Name: "Logistic Regression", New: func() Classifier { return &LogisticRegression{} }},
+	}
+}
+
+// ModelNamed returns the default model with the name, ignoring case and
+// allowing dashes for spaces, e.g. "naive-bayes".
+func ModelNamed(name string) (Model, error) {
+	for _, m := range DefaultModels() {
+		if strings.EqualFold(m.Name, strings.ReplaceAll(name, "-", " ")) {
+			return m, nil
+		}
+	}
+	return Model{}, fmt.Errorf("invalid model: %s", name)
+}
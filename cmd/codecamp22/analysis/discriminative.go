@@ -0,0 +1,126 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Ranking is a measure of how strongly a word indicates a class.
+type Ranking int
+
+const (
+	// LogLikelihoodRatio ranks by log P(word|class) - log P(word|other class)
+	LogLikelihoodRatio Ranking = iota
+	// ChiSquare ranks by the chi-square statistic of the word/class contingency table
+	ChiSquare
+	// MutualInformation ranks by the information the word carries about the class
+	MutualInformation
+)
+
+func (r Ranking) String() string {
+	switch r {
+	case LogLikelihoodRatio:
+		return "llr"
+	case ChiSquare:
+		return "chi2"
+	case MutualInformation:
+		return "mi"
+	default:
+		return ""
+	}
+}
+
+func RankingType(str string) (Ranking, error) {
+	switch str {
+	case LogLikelihoodRatio.String():
+		return LogLikelihoodRatio, nil
+	case ChiSquare.String():
+		return ChiSquare, nil
+	case MutualInformation.String():
+		return MutualInformation, nil
+	default:
+		return LogLikelihoodRatio, fmt.Errorf("invalid ranking: %s", str)
+	}
+}
+
+type WordScore struct {
+	Word  string
+	Score float64
+}
+
+// DiscriminativeWords returns the n words that most strongly indicate the
+// class, highest score first. Only words that are relatively more common in
+// the class than in the other class are considered. A negative n returns none,
+// like 0.
+func (ts TrainingSet) DiscriminativeWords(class experiment.Class, n int, r Ranking) []WordScore {
+	this, other := ts.Ham, ts.Spam
+	if class == experiment.SpamClass {
+		this, other = ts.Spam, ts.Ham
+	}
+
+	// only the words of the vocabulary count, as in the Naive Bayes model
+	thisWords, otherWords := this.WordFrequency.restrict(ts.Vocabulary), other.WordFrequency.restrict(ts.Vocabulary)
+	var scores []WordScore
+	for _, word := range ts.Vocabulary {
+		var score float64
+		if r == LogLikelihoodRatio {
+			// the smoothed probabilities decide which class the word leans towards
+			score = math.Log(thisWords.probability(word, ts.Vocabulary, ts.alpha())) -
+				math.Log(otherWords.probability(word, ts.Vocabulary, ts.alpha()))
+			if score <= 0 {
+				continue
+			}
+		} else {
+			// the share of messages containing the word decides which class it leans towards
+			a, b := float64(this.DocumentFrequency[word]), float64(other.DocumentFrequency[word])
+			if a/float64(this.MessageTotal) <= b/float64(other.MessageTotal) {
+				continue
+			}
+			c, d := float64(this.MessageTotal)-a, float64(other.MessageTotal)-b
+			score = contingencyScore(a, b, c, d, r)
+		}
+		scores = append(scores, WordScore{Word: word, Score: score})
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].Word < scores[j].Word
+	})
+	if n < 0 {
+		n = 0
+	}
+	if len(scores) > n {
+		scores = scores[:n]
+	}
+	return scores
+}
+
+// contingencyScore computes chi-square or mutual information for the table
+//
+//	            class  other
+//	word          a      b
+//	no word       c      d
+func contingencyScore(a, b, c, d float64, r Ranking) float64 {
+	n := a + b + c + d
+	if r == ChiSquare {
+		denominator := (a + c) * (b + d) * (a + b) * (c + d)
+		if denominator == 0 {
+			return 0
+		}
+		return n * (a*d - b*c) * (a*d - b*c) / denominator
+	}
+
+	// mutual information sums over the four cells of the table
+	cell := func(count, row, column float64) float64 {
+		if count == 0 {
+			return 0
+		}
+		return count / n * math.Log2(n*count/(row*column))
+	}
+	return cell(a, a+b, a+c) + cell(b, a+b, b+d) + cell(c, c+d, a+c) + cell(d, c+d, b+d)
+}
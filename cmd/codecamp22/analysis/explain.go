@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Explainer is implemented by classifiers that can break a classification
+// down into the contribution of each word.
+type Explainer interface {
+	Explain(text string) Explanation
+}
+
+// WordContribution is how much one word of a message pushed the
+// classification towards spam (positive log odds) or ham (negative).
+type WordContribution struct {
+	Word         string `json:"word"`
+	InVocabulary bool   `json:"inVocabulary"`
+	// Weight is the number of occurrences, or the TF-IDF weight of the word
+	Weight   float64 `json:"weight"`
+	LogPHam  float64 `json:"logPHam"`
+	LogPSpam float64 `json:"logPSpam"`
+	// LogOdds is Weight * (log P(word|spam) - log P(word|ham))
+	LogOdds float64 `json:"logOdds"`
+}
+
+type Explanation struct {
+	Text            string             `json:"text"`
+	Class           experiment.Class   `json:"class"`
+	SpamProbability float64            `json:"spamProbability"`
+	LogOdds         float64            `json:"logOdds"`
+	Words           []WordContribution `json:"words"`
+}
+
+// Explain classifies the message and lists every word with its log
+// probabilities and log odds contribution, most influential first. Words that
+// are not in the vocabulary are listed last with no contribution.
+func (nb *NaiveBayes) Explain(text string) Explanation {
+	class, probability := nb.Model.Score(text)
+	explanation := Explanation{
+		Text:            text,
+		Class:           experimentClass(class),
+		SpamProbability: probability,
+		LogOdds:         nb.Model.LogOdds(text),
+	}
+	for word, weight := range nb.Model.Weights(text) {
+		contribution := WordContribution{Word: word, Weight: weight}
+		hamProbability, exists := nb.Model.Probability(bayes.HamClass, word)
+		if exists {
+			spamProbability, _ := nb.Model.Probability(bayes.SpamClass, word)
+			contribution.InVocabulary = true
+			contribution.LogPHam = math.Log(hamProbability)
+			contribution.LogPSpam = math.Log(spamProbability)
+			contribution.LogOdds = weight * (contribution.LogPSpam - contribution.LogPHam)
+		}
+		explanation.Words = append(explanation.Words, contribution)
+	}
+	sort.Slice(explanation.Words, func(i, j int) bool {
+		a, b := explanation.Words[i], explanation.Words[j]
+		if a.InVocabulary != b.InVocabulary {
+			return a.InVocabulary
+		}
+		if math.Abs(a.LogOdds) != math.Abs(b.LogOdds) {
+			return math.Abs(a.LogOdds) > math.Abs(b.LogOdds)
+		}
+		return a.Word < b.Word
+	})
+	return explanation
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"math/rand"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// DefaultFractions are the shares of the training split LearningCurve trains
+// on when none are given.
+var DefaultFractions = []float64{.1, .2, .3, .4, .5, .6, .7, .8, .9, 1}
+
+// LearningPoint is the accuracy of a model trained on part of the training split.
+type LearningPoint struct {
+	Fraction         float64
+	TrainingMessages int
+	// TrainAccuracy is measured on the messages the model was trained on,
+	// TestAccuracy on the test split
+	TrainAccuracy float64
+	TestAccuracy  float64
+}
+
+// LearningCurve trains and tests the model on the pipeline once for every
+// fraction of the training split. The training messages are shuffled with
+// the seed once, and every fraction takes the first messages of each class,
+// so the classes keep their proportions and larger fractions include the
+// messages of smaller ones. A test accuracy that still rises at the largest
+// fraction means more data would likely help.
+func LearningCurve(ex experiment.Experiment, p Pipeline, m Model, fractions []float64, seed int64) []LearningPoint {
+	if len(fractions) == 0 {
+		fractions = DefaultFractions
+	}
+	pex := ex.Copy()
+	for _, pre := range p.Preprocessors {
+		pre.Process(&pex)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(pex.Classes.Ham), func(i, j int) {
+		pex.Classes.Ham[i], pex.Classes.Ham[j] = pex.Classes.Ham[j], pex.Classes.Ham[i]
+	})
+	rng.Shuffle(len(pex.Classes.Spam), func(i, j int) {
+		pex.Classes.Spam[i], pex.Classes.Spam[j] = pex.Classes.Spam[j], pex.Classes.Spam[i]
+	})
+
+	var points []LearningPoint
+	for _, fraction := range fractions {
+		classes := experiment.Classes{
+			Ham:  pex.Classes.Ham[:share(len(pex.Classes.Ham), fraction)],
+			Spam: pex.Classes.Spam[:share(len(pex.Classes.Spam), fraction)],
+		}
+		if len(classes.Ham) == 0 || len(classes.Spam) == 0 {
+			continue
+		}
+		var seen experiment.TestSet
+		for _, msg := range classes.Ham {
+			seen.Cases = append(seen.Cases, experiment.TestCase{Class: experiment.HamClass, Text: msg})
+		}
+		for _, msg := range classes.Spam {
+			seen.Cases = append(seen.Cases, experiment.TestCase{Class: experiment.SpamClass, Text: msg})
+		}
+		classifier := Train(p, m, classes)
+		points = append(points, LearningPoint{
+			Fraction:         fraction,
+			TrainingMessages: len(seen.Cases),
+			TrainAccuracy:    Evaluate(classifier, seen).Accuracy(),
+			TestAccuracy:     Evaluate(classifier, pex.Test).Accuracy(),
+		})
+	}
+	return points
+}
+
+// share is the number of n items in the fraction, at least one when any.
+func share(n int, fraction float64) int {
+	k := int(float64(n)*fraction + .5)
+	if k > n {
+		k = n
+	}
+	if k < 1 && n > 0 && fraction > 0 {
+		k = 1
+	}
+	return k
+}
@@ -0,0 +1,191 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Defaults used by LogisticRegression for fields left at their zero value.
+const (
+	defaultLearningRate = 0.1
+	defaultLambda       = 1e-4
+	defaultEpochs       = 10
+
+	// minScale is how small the scale of the weights gets during training
+	// before it is folded back into them
+	minScale = 1e-9
+)
+
+// LogisticRegression is an L2 regularized logistic regression over the bag of
+// words of a message, trained with stochastic gradient descent. The zero value
+// is ready to use with sensible defaults.
+type LogisticRegression struct {
+	// LearningRate is the SGD step size
+	LearningRate float64
+	// Lambda is the strength of the L2 penalty on the weights
+	Lambda float64
+	// Epochs is the number of passes over the training messages
+	Epochs int
+	// Seed makes the order the messages are visited in reproducible
+	Seed int64
+	// TFIDF, when set, uses TF-IDF weights as feature values instead of
+	// raw word counts.
+	TFIDF *TFIDF
+	// Selection limits the vocabulary that is used as features
+	Selection FeatureSelection
+
+	tfidfOptions *TFIDFOptions
+
+	// index maps each word of the vocabulary to its feature
+	index   map[string]int
+	weights []float64
+	bias    float64
+}
+
+// features is a sparse bag of words, feature index to word count.
+type features map[int]float64
+
+// Hyperparameters returns the learning rate, lambda and epochs training uses,
+// the defaults for the fields left at zero.
+func (lr *LogisticRegression) Hyperparameters() (float64, float64, int) {
+	learningRate, lambda, epochs := lr.LearningRate, lr.Lambda, lr.Epochs
+	if learningRate == 0 {
+		learningRate = defaultLearningRate
+	}
+	if lambda == 0 {
+		lambda = defaultLambda
+	}
+	if epochs == 0 {
+		epochs = defaultEpochs
+	}
+	return learningRate, lambda, epochs
+}
+
+func (lr *LogisticRegression) Train(classes experiment.Classes) {
+	learningRate, lambda, epochs := lr.Hyperparameters()
+
+	vocabulary := vocabularyFrom(classes.Ham, classes.Spam)
+	if lr.Selection != (FeatureSelection{}) {
+		vocabulary = lr.Selection.Select(countTrainingSet(classes))
+	}
+	lr.index = make(map[string]int, len(vocabulary))
+	for i, word := range vocabulary {
+		lr.index[word] = i
+	}
+	lr.weights = make([]float64, len(vocabulary))
+	lr.bias = 0
+	lr.TFIDF = nil
+	if lr.tfidfOptions != nil {
+		lr.TFIDF = NewTFIDF(*lr.tfidfOptions, classes.Ham, classes.Spam)
+	}
+
+	type sample struct {
+		x features
+		y float64
+	}
+	var samples []sample
+	for _, msg := range classes.Ham {
+		samples = append(samples, sample{x: lr.features(msg), y: 0})
+	}
+	for _, msg := range classes.Spam {
+		samples = append(samples, sample{x: lr.features(msg), y: 1})
+	}
+
+	// The true weights are scale*weights. Decaying the scale applies the L2
+	// penalty to every weight at once, so each step only touches the words
+	// of the current message.
+	decay := 1 - learningRate*lambda
+	scale := 1.0
+	rng := rand.New(rand.NewSource(lr.Seed))
+	for epoch := 0; epoch < epochs; epoch++ {
+		rng.Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+		for _, s := range samples {
+			gradient := sigmoid(scale*lr.dot(s.x)+lr.bias) - s.y
+			if decay <= 0 {
+				// a penalty this strong takes the weights all the way to zero
+				lr.rescale(0)
+				scale = 1
+			} else {
+				scale *= decay
+			}
+			for j, value := range s.x {
+				lr.weights[j] -= learningRate * gradient * value / scale
+			}
+			lr.bias -= learningRate * gradient
+			if scale < minScale {
+				lr.rescale(scale)
+				scale = 1
+			}
+		}
+		lr.rescale(scale)
+		scale = 1
+	}
+}
+
+// rescale folds the scale back into the weights, before it gets small enough
+// to lose precision or reach zero.
+func (lr *LogisticRegression) rescale(scale float64) {
+	for j := range lr.weights {
+		lr.weights[j] *= scale
+	}
+}
+
+func (lr *LogisticRegression) UseTFIDF(opts TFIDFOptions) {
+	lr.tfidfOptions = &opts
+}
+
+func (lr *LogisticRegression) UseFeatureSelection(fs FeatureSelection) {
+	lr.Selection = fs
+}
+
+func (lr *LogisticRegression) Predict(text string) experiment.Class {
+	class, _ := lr.Score(text)
+	return class
+}
+
+// Score returns the class of the message and the probability that it is spam.
+func (lr *LogisticRegression) Score(text string) (experiment.Class, float64) {
+	p := lr.PredictProba(text)
+	if p > 0.5 {
+		return experiment.SpamClass, p
+	}
+	return experiment.HamClass, p
+}
+
+func (lr *LogisticRegression) PredictProba(text string) float64 {
+	return sigmoid(lr.dot(lr.features(text)) + lr.bias)
+}
+
+// features counts the words of the message that are in the vocabulary, or
+// weights them with TF-IDF.
+func (lr *LogisticRegression) features(text string) features {
+	x := make(features)
+	if lr.TFIDF != nil {
+		for word, weight := range lr.TFIDF.Weights(text) {
+			if j, exists := lr.index[word]; exists {
+				x[j] = weight
+			}
+		}
+		return x
+	}
+	for _, word := range words(text) {
+		if j, exists := lr.index[word]; exists {
+			x[j]++
+		}
+	}
+	return x
+}
+
+func (lr *LogisticRegression) dot(x features) float64 {
+	var sum float64
+	for j, value := range x {
+		sum += lr.weights[j] * value
+	}
+	return sum
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
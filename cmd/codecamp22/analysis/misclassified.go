@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Mistake is a kind of misclassification, with spam as the positive class.
+type Mistake int
+
+const (
+	// AnyMistake is a false positive or a false negative
+	AnyMistake Mistake = iota
+	// FalsePositive is ham classified as spam
+	FalsePositive
+	// FalseNegative is spam classified as ham
+	FalseNegative
+)
+
+func (m Mistake) String() string {
+	switch m {
+	case AnyMistake:
+		return "all"
+	case FalsePositive:
+		return "fp"
+	case FalseNegative:
+		return "fn"
+	default:
+		return ""
+	}
+}
+
+// MistakeType returns the mistake with the name String gives it, e.g. "fp".
+func MistakeType(str string) (Mistake, error) {
+	switch str {
+	case AnyMistake.String():
+		return AnyMistake, nil
+	case FalsePositive.String():
+		return FalsePositive, nil
+	case FalseNegative.String():
+		return FalseNegative, nil
+	default:
+		return AnyMistake, fmt.Errorf("invalid mistake: %s", str)
+	}
+}
+
+// Misclassification is a test case an analysis classified incorrectly.
+type Misclassification struct {
+	Analysis string
+	Model    string
+	Prediction
+	Mistake Mistake
+	// Original is the text of the test case before preprocessing
+	Original string
+	// Words are the words that pushed the classification the wrong way the
+	// most, for the classifiers that can explain it
+	Words []WordContribution
+}
+
+// Confidence is the probability the classifier gave to its wrong class.
+func (m Misclassification) Confidence() float64 {
+	if m.Predicted == experiment.SpamClass {
+		return m.SpamProbability
+	}
+	return 1 - m.SpamProbability
+}
+
+// MisclassificationFilter selects the misclassifications to list.
+type MisclassificationFilter struct {
+	Mistake Mistake
+	// MinConfidence leaves out the mistakes the classifier was less sure of
+	MinConfidence float64
+	// Contains leaves out the test cases without this text, ignoring case
+	Contains string
+	// Words is the number of contributing words listed with every mistake
+	Words int
+}
+
+// Misclassified lists the test cases the analyses got wrong, the most
+// confident mistakes first. The experiment the analyses were tested on gives
+// the text of the test cases before preprocessing.
+func Misclassified(ex experiment.Experiment, analyses Analyses, filter MisclassificationFilter) []Misclassification {
+	contains := strings.ToLower(filter.Contains)
+	var mistakes []Misclassification
+	for _, a := range analyses {
+		for _, p := range a.TestSet.Predictions {
+			if p.Predicted == p.Class {
+				continue
+			}
+			m := Misclassification{Analysis: a.Name, Model: a.Model, Prediction: p, Mistake: FalseNegative, Original: p.Text}
+			if p.Predicted == experiment.SpamClass {
+				m.Mistake = FalsePositive
+			}
+			if p.Case < len(ex.Test.Cases) {
+				m.Original = ex.Test.Cases[p.Case].Text
+			}
+			if filter.Mistake != AnyMistake && m.Mistake != filter.Mistake {
+				continue
+			}
+			if m.Confidence() < filter.MinConfidence {
+				continue
+			}
+			if contains != "" && !strings.Contains(strings.ToLower(m.Original), contains) {
+				continue
+			}
+			if explainer, ok := a.Classifier.(Explainer); ok && filter.Words > 0 {
+				m.Words = misleadingWords(explainer.Explain(p.Text), p.Predicted, filter.Words)
+			}
+			mistakes = append(mistakes, m)
+		}
+	}
+	sort.SliceStable(mistakes, func(i, j int) bool {
+		return mistakes[i].Confidence() > mistakes[j].Confidence()
+	})
+	return mistakes
+}
+
+// misleadingWords returns the top words of the explanation that pushed the
+// classification towards the predicted class.
+func misleadingWords(e Explanation, predicted experiment.Class, top int) []WordContribution {
+	var words []WordContribution
+	for _, w := range e.Words {
+		if len(words) == top {
+			break
+		}
+		if (predicted == experiment.SpamClass && w.LogOdds > 0) || (predicted == experiment.HamClass && w.LogOdds < 0) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
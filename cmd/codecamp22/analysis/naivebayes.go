@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Prior tells whether Naive Bayes weighs the classes by how common they are.
+type Prior int
+
+const (
+	// UniformPrior scores the classes by the words of a message only, as if
+	// ham and spam were equally common
+	UniformPrior Prior = iota
+	// ClassPrior also adds the log share of the training messages of a class
+	ClassPrior
+)
+
+func (p Prior) String() string {
+	switch p {
+	case UniformPrior:
+		return "uniform"
+	case ClassPrior:
+		return "class"
+	default:
+		return ""
+	}
+}
+
+func PriorType(str string) (Prior, error) {
+	switch str {
+	case UniformPrior.String():
+		return UniformPrior, nil
+	case ClassPrior.String():
+		return ClassPrior, nil
+	default:
+		return UniformPrior, fmt.Errorf("invalid prior: %s", str)
+	}
+}
+
+// NaiveBayes is a multinomial Naive Bayes classifier over the words of a
+// message, built on the classifier of the bayes package.
+type NaiveBayes struct {
+	// TrainingSet holds the word counts of the training messages and the
+	// vocabulary the feature selection kept. Learn and Unlearn update the
+	// model only.
+	TrainingSet TrainingSet
+	// Model is the trained classifier
+	Model *bayes.Classifier
+	// Alpha is the additive smoothing of the word counts, 1 when zero
+	Alpha float64
+	Prior Prior
+	// TFIDF, when set, trains and scores on TF-IDF weighted words instead of
+	// raw word counts.
+	TFIDF *TFIDF
+
+	// Selection limits the vocabulary the model learns
+	Selection FeatureSelection
+	// Preprocessors names the preprocessing of the messages for the model,
+	// see bayes.Options
+	Preprocessors []string
+
+	tfidfOptions *TFIDFOptions
+}
+
+func (nb *NaiveBayes) UseTFIDF(opts TFIDFOptions) {
+	nb.tfidfOptions = &opts
+}
+
+func (nb *NaiveBayes) UseFeatureSelection(fs FeatureSelection) {
+	nb.Selection = fs
+}
+
+func (nb *NaiveBayes) Train(classes experiment.Classes) {
+	nb.TrainingSet = newTrainingSet(classes, nb.Selection)
+	nb.TrainingSet.Alpha = nb.Alpha
+	nb.TFIDF = nil
+	if nb.tfidfOptions != nil {
+		nb.TFIDF = NewTFIDF(*nb.tfidfOptions, classes.Ham, classes.Spam)
+	}
+	nb.Model = nb.newModel(nb.TrainingSet)
+	var examples []bayes.Example
+	for _, msg := range classes.Ham {
+		examples = append(examples, bayes.Example{Class: bayes.HamClass, Text: msg})
+	}
+	for _, msg := range classes.Spam {
+		examples = append(examples, bayes.Example{Class: bayes.SpamClass, Text: msg})
+	}
+	nb.Model.Train(examples)
+}
+
+// newModel returns an untrained model with the settings of nb, restricted to
+// the vocabulary of the training set when a feature selection chose it.
+func (nb *NaiveBayes) newModel(ts TrainingSet) *bayes.Classifier {
+	opts := bayes.Options{Alpha: nb.Alpha, ClassPrior: nb.Prior == ClassPrior, Preprocessors: nb.Preprocessors}
+	if nb.TFIDF != nil {
+		opts.Weigh = nb.TFIDF.Weights
+	}
+	model := bayes.New(opts)
+	if ts.selected {
+		model.Restrict(ts.Vocabulary)
+	}
+	return model
+}
+
+func (nb *NaiveBayes) Predict(text string) experiment.Class {
+	return experimentClass(nb.Model.Classify(text))
+}
+
+func (nb *NaiveBayes) PredictProba(text string) float64 {
+	return nb.Model.SpamProbability(text)
+}
+
+// Score returns the class of the message and the probability that it is spam.
+func (nb *NaiveBayes) Score(text string) (experiment.Class, float64) {
+	class, p := nb.Model.Score(text)
+	return experimentClass(class), p
+}
+
+func bayesClass(class experiment.Class) bayes.Class {
+	if class == experiment.SpamClass {
+		return bayes.SpamClass
+	}
+	return bayes.HamClass
+}
+
+func experimentClass(class bayes.Class) experiment.Class {
+	if class == bayes.SpamClass {
+		return experiment.SpamClass
+	}
+	return experiment.HamClass
+}
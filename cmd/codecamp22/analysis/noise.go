@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Suspect is a labeled message that a model trained without it confidently
+// puts in the other class.
+type Suspect struct {
+	// Line is the line of the original dataset the message was read from, and
+	// Source the file, if any
+	Line      int
+	Source    string
+	Label     experiment.Class
+	Predicted experiment.Class
+	// Confidence is the probability the model gives the predicted class
+	Confidence float64
+	Text       string
+}
+
+// Location names where the message was read from, e.g. "line 12" or
+// "spam/2022.mbox line 12".
+func (s Suspect) Location() string {
+	return location(s.Source, s.Line)
+}
+
+// CrossValidate splits the labeled messages into folds and returns, for every
+// message, the probability that it is spam according to the model trained on
+// the pipeline with the other folds. The messages are assigned to folds at
+// random using seed.
+func CrossValidate(cases []experiment.TestCase, p Pipeline, m Model, folds int, seed int64) []float64 {
+	if folds < 2 {
+		folds = 2
+	}
+	fold := make([]int, len(cases))
+	for i, j := range rand.New(rand.NewSource(seed)).Perm(len(cases)) {
+		fold[j] = i % folds
+	}
+
+	probabilities := make([]float64, len(cases))
+	for f := 0; f < folds; f++ {
+		var ex experiment.Experiment
+		var held []int
+		for i, tc := range cases {
+			switch {
+			case fold[i] == f:
+				ex.Test.Cases = append(ex.Test.Cases, tc)
+				held = append(held, i)
+			case tc.Class == experiment.SpamClass:
+				ex.Classes.Spam = append(ex.Classes.Spam, tc.Text)
+			default:
+				ex.Classes.Ham = append(ex.Classes.Ham, tc.Text)
+			}
+		}
+		for _, pre := range p.Preprocessors {
+			pre.Process(&ex)
+		}
+		classifier := Train(p, m, ex.Classes)
+		for j, tc := range ex.Test.Cases {
+			probabilities[held[j]] = classifier.PredictProba(tc.Text)
+		}
+	}
+	return probabilities
+}
+
+// LabelNoise returns the messages whose cross validated prediction disagrees
+// with their label with at least the given confidence, most confident first.
+func LabelNoise(cases []experiment.TestCase, p Pipeline, m Model, folds int, seed int64, confidence float64) []Suspect {
+	var suspects []Suspect
+	for i, spam := range CrossValidate(cases, p, m, folds, seed) {
+		suspect := Suspect{Line: cases[i].Line, Source: cases[i].Source, Label: cases[i].Class, Text: cases[i].Text}
+		if cases[i].Class == experiment.HamClass {
+			suspect.Predicted, suspect.Confidence = experiment.SpamClass, spam
+		} else {
+			suspect.Predicted, suspect.Confidence = experiment.HamClass, 1-spam
+		}
+		if suspect.Confidence >= confidence {
+			suspects = append(suspects, suspect)
+		}
+	}
+	sort.SliceStable(suspects, func(i, j int) bool {
+		return suspects[i].Confidence > suspects[j].Confidence
+	})
+	return suspects
+}
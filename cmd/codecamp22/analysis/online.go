@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"errors"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// OnlineClassifier is a classifier that can be updated with single labeled
+// messages after it has been trained, without training from scratch.
+type OnlineClassifier interface {
+	Classifier
+	// Learn adds a labeled message to the model.
+	Learn(class experiment.Class, text string) error
+	// Unlearn removes a message that was learned with that label before.
+	Unlearn(class experiment.Class, text string) error
+}
+
+var errTFIDFNotOnline = errors.New("incremental training is not supported with TF-IDF weighting")
+
+func (nb *NaiveBayes) Learn(class experiment.Class, text string) error {
+	if nb.TFIDF != nil {
+		return errTFIDFNotOnline
+	}
+	return nb.Model.Learn(bayesClass(class), text)
+}
+
+func (nb *NaiveBayes) Unlearn(class experiment.Class, text string) error {
+	if nb.TFIDF != nil {
+		return errTFIDFNotOnline
+	}
+	return nb.Model.Unlearn(bayesClass(class), text)
+}
+
+// wordCounts counts how many times each word occurs in the message.
+func wordCounts(msg string) map[string]int {
+	counts := make(map[string]int)
+	for _, word := range words(msg) {
+		counts[word]++
+	}
+	return counts
+}
@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+)
+
+// SearchSpace lists the values to try for every Naive Bayes hyperparameter.
+// An empty list tries only the default.
+type SearchSpace struct {
+	Pipelines []Pipeline
+	// Alphas are the additive smoothings of the word counts
+	Alphas []float64
+	// NGrams are the longest word sequences counted as words, 1 for single words
+	NGrams []int
+	// MinDFs and MaxVocabularies are the vocabulary cutoffs, ranked by Ranking
+	MinDFs          []int
+	MaxVocabularies []int
+	Ranking         Ranking
+	Priors          []Prior
+}
+
+// Candidate is one combination of hyperparameters.
+type Candidate struct {
+	Pipeline Pipeline
+	Alpha    float64
+	NGrams   int
+	MinDF    int
+	MaxVocab int
+	Ranking  Ranking
+	Prior    Prior
+}
+
+func (c Candidate) String() string {
+	return fmt.Sprintf("%s, alpha %g, %d-grams, min df %d, max vocabulary %d, %s prior",
+		c.Pipeline.Name, c.Alpha, c.NGrams, c.MinDF, c.MaxVocab, c.Prior)
+}
+
+// Model returns the Naive Bayes model with the smoothing and prior of the candidate.
+func (c Candidate) Model() Model {
+	return Model{Name: "Naive Bayes", New: func() Classifier {
+		return &NaiveBayes{Alpha: c.Alpha, Prior: c.Prior}
+	}}
+}
+
+// WithFeatures returns the pipeline of the candidate with its n-grams added
+// after the other preprocessors, and its vocabulary cutoff.
+func (c Candidate) WithFeatures() Pipeline {
+	p := c.Pipeline
+	p.Preprocessors = append([]Preprocessor(nil), c.Pipeline.Preprocessors...)
+	if c.NGrams > 1 {
+		p.Preprocessors = append(p.Preprocessors, parse.PreprocessNGrams{N: c.NGrams})
+	}
+	if c.MinDF > 0 || c.MaxVocab > 0 {
+		p.Selection = &FeatureSelection{MinDocumentFrequency: c.MinDF, MaxVocabulary: c.MaxVocab, Ranking: c.Ranking}
+	}
+	return p
+}
+
+// Grid returns every combination of the search space.
+func (s SearchSpace) Grid() []Candidate {
+	pipelines := s.Pipelines
+	if len(pipelines) == 0 {
+		pipelines = DefaultPipelines()[:1]
+	}
+	alphas, ngrams := orDefault(s.Alphas, 1), orDefault(s.NGrams, 1)
+	minDFs, maxVocabularies := orDefault(s.MinDFs, 0), orDefault(s.MaxVocabularies, 0)
+	priors := orDefault(s.Priors, UniformPrior)
+
+	var candidates []Candidate
+	for _, p := range pipelines {
+		for _, alpha := range alphas {
+			for _, n := range ngrams {
+				for _, minDF := range minDFs {
+					for _, maxVocab := range maxVocabularies {
+						for _, prior := range priors {
+							candidates = append(candidates, Candidate{
+								Pipeline: p, Alpha: alpha, NGrams: n, MinDF: minDF, MaxVocab: maxVocab, Ranking: s.Ranking, Prior: prior,
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return candidates
+}
+
+// Random returns n combinations of the search space drawn at random without
+// repeating any, or all of them when there are not more than n.
+func (s SearchSpace) Random(n int, seed int64) []Candidate {
+	grid := s.Grid()
+	if n <= 0 || n >= len(grid) {
+		return grid
+	}
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(grid), func(i, j int) { grid[i], grid[j] = grid[j], grid[i] })
+	return grid[:n]
+}
+
+func orDefault[T any](values []T, value T) []T {
+	if len(values) == 0 {
+		return []T{value}
+	}
+	return values
+}
+
+// SearchResult is the cross validated score of a candidate.
+type SearchResult struct {
+	Candidate
+	Accuracy float64
+	// F1 is the F1 score of the spam class
+	F1 float64
+}
+
+// Search scores every candidate by cross validation over the cases, with the
+// same folds for all of them, and returns the results best first: by
+// accuracy, then by F1.
+func Search(cases []experiment.TestCase, candidates []Candidate, folds int, seed int64) []SearchResult {
+	//Preprocess the cases once for every pipeline and n-gram length, rather
+	//than once for every fold of every candidate
+	type features struct {
+		pipeline string
+		ngrams   int
+	}
+	processed := make(map[features][]experiment.TestCase)
+	results := make([]SearchResult, len(candidates))
+	for i, c := range candidates {
+		p := c.WithFeatures()
+		key := features{c.Pipeline.Name, c.NGrams}
+		if _, exists := processed[key]; !exists {
+			ex := experiment.Experiment{Test: experiment.TestSet{Cases: append([]experiment.TestCase(nil), cases...)}}
+			for _, pre := range p.Preprocessors {
+				pre.Process(&ex)
+			}
+			processed[key] = ex.Test.Cases
+		}
+		p.Preprocessors = nil
+
+		var tp, fp, fn, correct int
+		for j, spam := range CrossValidate(processed[key], p, c.Model(), folds, seed) {
+			predicted := spam > .5
+			actual := cases[j].Class == experiment.SpamClass
+			switch {
+			case predicted == actual:
+				correct++
+				if actual {
+					tp++
+				}
+			case predicted:
+				fp++
+			default:
+				fn++
+			}
+		}
+		results[i] = SearchResult{Candidate: c}
+		if len(cases) > 0 {
+			results[i].Accuracy = float64(correct) / float64(len(cases))
+		}
+		if tp > 0 {
+			results[i].F1 = 2 * float64(tp) / float64(2*tp+fp+fn)
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Accuracy != results[j].Accuracy {
+			return results[i].Accuracy > results[j].Accuracy
+		}
+		return results[i].F1 > results[j].F1
+	})
+	return results
+}
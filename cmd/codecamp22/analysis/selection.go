@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// FeatureSelection limits the vocabulary a classifier is trained on. The zero
+// value keeps every word.
+type FeatureSelection struct {
+	// MinDocumentFrequency drops words that occur in fewer training messages
+	MinDocumentFrequency int
+	// MaxVocabulary keeps only this many of the highest ranked words, 0 keeps all
+	MaxVocabulary int
+	// Ranking decides which words are kept when the vocabulary is capped
+	Ranking Ranking
+}
+
+// Selective is implemented by classifiers that can limit their vocabulary
+// with feature selection.
+type Selective interface {
+	UseFeatureSelection(fs FeatureSelection)
+}
+
+// Select returns the words of the training set vocabulary that pass the
+// selection, highest ranked first when the vocabulary is capped.
+func (fs FeatureSelection) Select(ts TrainingSet) Vocabulary {
+	if fs == (FeatureSelection{}) {
+		return ts.Vocabulary
+	}
+	var vocabulary Vocabulary
+	for _, word := range ts.Vocabulary {
+		if ts.Ham.DocumentFrequency[word]+ts.Spam.DocumentFrequency[word] < fs.MinDocumentFrequency {
+			continue
+		}
+		vocabulary = append(vocabulary, word)
+	}
+	if fs.MaxVocabulary <= 0 || len(vocabulary) <= fs.MaxVocabulary {
+		return vocabulary
+	}
+
+	scores := make(map[string]float64, len(vocabulary))
+	for _, word := range vocabulary {
+		scores[word] = ts.featureScore(word, fs.Ranking)
+	}
+	sort.SliceStable(vocabulary, func(i, j int) bool {
+		if scores[vocabulary[i]] != scores[vocabulary[j]] {
+			return scores[vocabulary[i]] > scores[vocabulary[j]]
+		}
+		return vocabulary[i] < vocabulary[j]
+	})
+	return vocabulary[:fs.MaxVocabulary]
+}
+
+// featureScore is how informative the word is about either class. Unlike
+// DiscriminativeWords it does not care which class the word points to.
+func (ts TrainingSet) featureScore(word string, r Ranking) float64 {
+	if r == LogLikelihoodRatio {
+		return math.Abs(math.Log(ts.Spam.WordFrequency.probability(word, ts.Vocabulary, 1)) -
+			math.Log(ts.Ham.WordFrequency.probability(word, ts.Vocabulary, 1)))
+	}
+	a, b := float64(ts.Spam.DocumentFrequency[word]), float64(ts.Ham.DocumentFrequency[word])
+	c, d := float64(ts.Spam.MessageTotal)-a, float64(ts.Ham.MessageTotal)-b
+	return contingencyScore(a, b, c, d, r)
+}
+
+// VocabularyPoint is the accuracy of a model trained on a capped vocabulary.
+type VocabularyPoint struct {
+	MaxVocabulary  int
+	VocabularySize int
+	Accuracy       float64
+}
+
+// VocabularyCurve trains and tests the model on the pipeline once for every
+// vocabulary cap in sizes, using the ranking and minimum document frequency
+// of the selection.
+func VocabularyCurve(ex experiment.Experiment, p Pipeline, m Model, selection FeatureSelection, sizes []int) []VocabularyPoint {
+	pex := ex.Copy()
+	for _, pre := range p.Preprocessors {
+		pre.Process(&pex)
+	}
+	full := countTrainingSet(pex.Classes)
+
+	var points []VocabularyPoint
+	for _, size := range sizes {
+		selection.MaxVocabulary = size
+		capped := selection
+		p.Selection = &capped
+		classifier := Train(p, m, pex.Classes)
+		points = append(points, VocabularyPoint{
+			MaxVocabulary:  size,
+			VocabularySize: len(selection.Select(full)),
+			Accuracy:       Evaluate(classifier, pex.Test).Accuracy(),
+		})
+	}
+	return points
+}
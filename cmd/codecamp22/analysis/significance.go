@@ -0,0 +1,190 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// DefaultBootstrapSamples is the number of resamples of the test cases a
+// bootstrap confidence interval is estimated from.
+const DefaultBootstrapSamples = 1000
+
+// McNemar is McNemar's test of whether two classifiers tested on the same
+// cases differ in accuracy. Only the cases exactly one of them gets right
+// count.
+type McNemar struct {
+	// FirstOnly and SecondOnly are the numbers of cases only the first or
+	// only the second classifier got right
+	FirstOnly  int
+	SecondOnly int
+	// Statistic is the chi-squared statistic with continuity correction,
+	// unless Exact is set, when there are too few disagreements for it and
+	// the p-value comes from the binomial distribution instead
+	Statistic float64
+	Exact     bool
+	PValue    float64
+}
+
+// McNemarTest pairs the results of two classifiers on the same test cases.
+func McNemarTest(first, second []bool) McNemar {
+	var m McNemar
+	for i := range first {
+		switch {
+		case first[i] && !second[i]:
+			m.FirstOnly++
+		case !first[i] && second[i]:
+			m.SecondOnly++
+		}
+	}
+	n := m.FirstOnly + m.SecondOnly
+	if n == 0 {
+		m.PValue = 1
+		return m
+	}
+	if n < 25 {
+		//Two sided binomial test of the smaller count with p = 1/2
+		m.Exact = true
+		k := m.FirstOnly
+		if m.SecondOnly < k {
+			k = m.SecondOnly
+		}
+		for i := 0; i <= k; i++ {
+			m.PValue += math.Exp(logChoose(n, i) - float64(n)*math.Ln2)
+		}
+		m.PValue = math.Min(1, 2*m.PValue)
+		return m
+	}
+	d := math.Abs(float64(m.FirstOnly-m.SecondOnly)) - 1
+	m.Statistic = d * d / float64(n)
+	//The upper tail of the chi-squared distribution with one degree of freedom
+	m.PValue = math.Erfc(math.Sqrt(m.Statistic / 2))
+	return m
+}
+
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+// Interval is a difference in accuracy with a 95% confidence interval.
+type Interval struct {
+	Difference float64
+	Lower      float64
+	Upper      float64
+}
+
+// Excludes tells whether the value lies outside of the interval.
+func (i Interval) Excludes(value float64) bool {
+	return value < i.Lower || value > i.Upper
+}
+
+// Bootstrap estimates the accuracy of second minus the accuracy of first, with
+// a 95% percentile interval over samples paired resamples of the test cases.
+func Bootstrap(first, second []bool, samples int, seed int64) Interval {
+	n := len(first)
+	if n == 0 {
+		return Interval{}
+	}
+	difference := func(pick func(int) int) float64 {
+		d := 0
+		for i := 0; i < n; i++ {
+			j := pick(i)
+			if second[j] {
+				d++
+			}
+			if first[j] {
+				d--
+			}
+		}
+		return float64(d) / float64(n)
+	}
+	interval := Interval{Difference: difference(func(i int) int { return i })}
+	if samples <= 0 {
+		samples = DefaultBootstrapSamples
+	}
+	rng := rand.New(rand.NewSource(seed))
+	differences := make([]float64, samples)
+	for s := range differences {
+		differences[s] = difference(func(int) int { return rng.Intn(n) })
+	}
+	sort.Float64s(differences)
+	interval.Lower = differences[int(.025*float64(samples))]
+	interval.Upper = differences[int(math.Min(.975*float64(samples), float64(samples-1)))]
+	return interval
+}
+
+// Comparison tells whether a pipeline is significantly better or worse than
+// the baseline pipeline with the same model.
+type Comparison struct {
+	Model    string
+	Baseline string
+	Pipeline string
+	// Accuracy is the accuracy of the pipeline minus that of the baseline
+	Accuracy Interval
+	McNemar  McNemar
+}
+
+// Significant tells whether both tests find a difference at the 5% level.
+func (c Comparison) Significant() bool {
+	return c.McNemar.PValue < .05 && c.Accuracy.Excludes(0)
+}
+
+// ComparePipelines compares every analysis with the analysis of the baseline
+// pipeline that has the same model, the first pipeline when baseline is
+// empty. The analyses must have been tested on the same split.
+func ComparePipelines(analyses Analyses, baseline string, samples int, seed int64) ([]Comparison, error) {
+	if len(analyses) == 0 {
+		return nil, nil
+	}
+	if baseline == "" {
+		baseline = analyses[0].Name
+	}
+	baselines := make(map[string]Analysis)
+	for _, a := range analyses {
+		if a.Name == baseline {
+			baselines[a.Model] = a
+		}
+	}
+	if len(baselines) == 0 {
+		return nil, fmt.Errorf("comparing pipelines: no pipeline named %q", baseline)
+	}
+	var comparisons []Comparison
+	for _, a := range analyses {
+		b, exists := baselines[a.Model]
+		if !exists || a.Name == baseline {
+			continue
+		}
+		if err := sameCases(a.TestSet, b.TestSet); err != nil {
+			return nil, fmt.Errorf("comparing %s with %s: %w", a.Name, b.Name, err)
+		}
+		first, second := b.TestSet.Correct(), a.TestSet.Correct()
+		comparisons = append(comparisons, Comparison{
+			Model:    a.Model,
+			Baseline: b.Name,
+			Pipeline: a.Name,
+			Accuracy: Bootstrap(first, second, samples, seed),
+			McNemar:  McNemarTest(first, second),
+		})
+	}
+	return comparisons, nil
+}
+
+// sameCases checks that two test sets hold the same cases in the same order,
+// by their lines in the dataset and their labels, since the text of a case
+// differs between pipelines. The error names first before second.
+func sameCases(first, second TestSet) error {
+	if len(first.Predictions) != len(second.Predictions) {
+		return fmt.Errorf("tested on %d and %d cases, not the same split", len(first.Predictions), len(second.Predictions))
+	}
+	for i, p := range first.Predictions {
+		q := second.Predictions[i]
+		if p.Line != q.Line || p.Source != q.Source || p.Class != q.Class {
+			return fmt.Errorf("test case %d is %s of the data in one and %s in the other, not the same split", i+1, p.Location(), q.Location())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Lengths summarizes a distribution of message lengths.
+type Lengths struct {
+	Min    int
+	Median int
+	Mean   float64
+	P90    int
+	Max    int
+}
+
+func lengthsOf(values []int) Lengths {
+	if len(values) == 0 {
+		return Lengths{}
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	total := 0
+	for _, v := range sorted {
+		total += v
+	}
+	return Lengths{
+		Min:    sorted[0],
+		Median: sorted[len(sorted)/2],
+		Mean:   float64(total) / float64(len(sorted)),
+		P90:    sorted[(len(sorted)*9)/10],
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// ClassStatistics describes the messages of one class.
+type ClassStatistics struct {
+	Class    experiment.Class
+	Messages int
+	// Characters and Words are the distributions of the message lengths
+	Characters Lengths
+	Words      Lengths
+}
+
+// WordCount is how many times a word occurs in a dataset.
+type WordCount struct {
+	Word  string
+	Count int
+}
+
+// Zipf summarizes the word frequencies of a dataset, which in natural
+// language fall off roughly as 1/rank^Exponent.
+type Zipf struct {
+	// Tokens is the number of words, Types the number of distinct words
+	Tokens int
+	Types  int
+	// Hapax is the number of words that occur only once
+	Hapax int
+	// Exponent is the slope of log frequency against log rank, fitted by
+	// least squares
+	Exponent float64
+	// Top10Share and Top100Share are the shares of all tokens taken by the
+	// 10 and the 100 most frequent words
+	Top10Share  float64
+	Top100Share float64
+	Top         []WordCount
+}
+
+// PipelineStatistics describes the vocabulary a pipeline trains on.
+type PipelineStatistics struct {
+	Name       string
+	Vocabulary int
+	// TestTokens is the number of words in the test split, OutOfVocabulary
+	// the number of them that never occur in the training split
+	TestTokens      int
+	OutOfVocabulary int
+}
+
+// HapaxRate is the share of distinct words that occur only once.
+func (z Zipf) HapaxRate() float64 {
+	if z.Types == 0 {
+		return 0
+	}
+	return float64(z.Hapax) / float64(z.Types)
+}
+
+// OutOfVocabularyRate is the share of test words that are not in the vocabulary.
+func (p PipelineStatistics) OutOfVocabularyRate() float64 {
+	if p.TestTokens == 0 {
+		return 0
+	}
+	return float64(p.OutOfVocabulary) / float64(p.TestTokens)
+}
+
+// Statistics describes a dataset and what each pipeline makes of it.
+type Statistics struct {
+	Classes   []ClassStatistics
+	Zipf      Zipf
+	Pipelines []PipelineStatistics
+}
+
+// Describe computes the statistics of the training messages and test cases of
+// the experiment, listing the top most frequent words.
+func Describe(ex experiment.Experiment, pipelines []Pipeline, top int) Statistics {
+	var stats Statistics
+	messages := map[experiment.Class][]string{
+		experiment.HamClass:  append([]string(nil), ex.Classes.Ham...),
+		experiment.SpamClass: append([]string(nil), ex.Classes.Spam...),
+	}
+	for _, tc := range ex.Test.Cases {
+		messages[tc.Class] = append(messages[tc.Class], tc.Text)
+	}
+	for _, class := range []experiment.Class{experiment.HamClass, experiment.SpamClass} {
+		var characters, wordCounts []int
+		for _, msg := range messages[class] {
+			characters = append(characters, len([]rune(msg)))
+			wordCounts = append(wordCounts, len(words(msg)))
+		}
+		stats.Classes = append(stats.Classes, ClassStatistics{
+			Class:      class,
+			Messages:   len(messages[class]),
+			Characters: lengthsOf(characters),
+			Words:      lengthsOf(wordCounts),
+		})
+	}
+	stats.Zipf = zipfOf(wordFrequencyFrom(append(messages[experiment.HamClass], messages[experiment.SpamClass]...)), top)
+
+	for _, p := range pipelines {
+		pex := ex.Copy()
+		for _, pre := range p.Preprocessors {
+			pre.Process(&pex)
+		}
+		vocabulary := make(map[string]bool)
+		for _, word := range vocabularyFrom(pex.Classes.Ham, pex.Classes.Spam) {
+			vocabulary[word] = true
+		}
+		ps := PipelineStatistics{Name: p.Name, Vocabulary: len(vocabulary)}
+		for _, tc := range pex.Test.Cases {
+			for _, word := range words(tc.Text) {
+				ps.TestTokens++
+				if !vocabulary[word] {
+					ps.OutOfVocabulary++
+				}
+			}
+		}
+		stats.Pipelines = append(stats.Pipelines, ps)
+	}
+	return stats
+}
+
+func zipfOf(frequency WordFrequency, top int) Zipf {
+	counts := make([]WordCount, 0, len(frequency))
+	z := Zipf{Types: len(frequency)}
+	for word, count := range frequency {
+		counts = append(counts, WordCount{Word: word, Count: count})
+		z.Tokens += count
+		if count == 1 {
+			z.Hapax++
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Word < counts[j].Word
+	})
+	if z.Tokens == 0 {
+		return z
+	}
+
+	//Fit log(count) = a - s*log(rank) by least squares
+	var sumX, sumY, sumXY, sumXX float64
+	for i, wc := range counts {
+		x, y := math.Log(float64(i+1)), math.Log(float64(wc.Count))
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+		if i < 10 {
+			z.Top10Share += float64(wc.Count)
+		}
+		if i < 100 {
+			z.Top100Share += float64(wc.Count)
+		}
+	}
+	n := float64(len(counts))
+	if d := n*sumXX - sumX*sumX; d != 0 {
+		z.Exponent = -(n*sumXY - sumX*sumY) / d
+	}
+	z.Top10Share /= float64(z.Tokens)
+	z.Top100Share /= float64(z.Tokens)
+	if top < 0 {
+		top = 0
+	}
+	if top > len(counts) {
+		top = len(counts)
+	}
+	z.Top = counts[:top]
+	return z
+}
@@ -0,0 +1,187 @@
+package analysis
+
+import (
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+)
+
+// MessagePreprocessor is implemented by preprocessors that can process a
+// single message, which is what streaming needs.
+type MessagePreprocessor interface {
+	ProcessMessage(original string) string
+}
+
+// StreamTrainer builds a training set and a Naive Bayes model one message at
+// a time, keeping only the word counts in memory and not the messages
+// themselves.
+type StreamTrainer struct {
+	ts    TrainingSet
+	known map[string]bool
+	model *bayes.Classifier
+}
+
+func NewStreamTrainer() *StreamTrainer {
+	return &StreamTrainer{
+		ts: TrainingSet{
+			Ham:  Class{WordFrequency: make(WordFrequency), DocumentFrequency: make(DocumentFrequency)},
+			Spam: Class{WordFrequency: make(WordFrequency), DocumentFrequency: make(DocumentFrequency)},
+		},
+		known: make(map[string]bool),
+		model: bayes.New(bayes.Options{}),
+	}
+}
+
+// Add counts the words of a labeled message.
+func (t *StreamTrainer) Add(class experiment.Class, msg string) {
+	c := &t.ts.Ham
+	if class == experiment.SpamClass {
+		c = &t.ts.Spam
+	}
+	c.MessageTotal++
+	t.ts.MessageTotal++
+	for word, count := range wordCounts(msg) {
+		c.WordFrequency[word] += count
+		c.DocumentFrequency[word]++
+		if !t.known[word] {
+			t.known[word] = true
+			t.ts.Vocabulary = append(t.ts.Vocabulary, word)
+		}
+	}
+	t.model.Learn(bayesClass(class), msg)
+}
+
+// TrainingSet returns the counts so far, with the vocabulary the selection
+// keeps. The returned training set shares its counts with the trainer, so it
+// should be called after the last Add.
+func (t *StreamTrainer) TrainingSet(selection FeatureSelection) TrainingSet {
+	ts := t.ts
+	if ts.MessageTotal > 0 {
+		ts.Ham.PofC = float64(ts.Ham.MessageTotal) / float64(ts.MessageTotal)
+		ts.Spam.PofC = float64(ts.Spam.MessageTotal) / float64(ts.MessageTotal)
+	}
+	ts.Vocabulary = selection.Select(ts)
+	ts.selected = selection != (FeatureSelection{})
+	return ts
+}
+
+// NaiveBayes returns the model trained on the messages so far, restricted to
+// the vocabulary the selection keeps. Like TrainingSet it should be called
+// after the last Add.
+func (t *StreamTrainer) NaiveBayes(selection FeatureSelection) *NaiveBayes {
+	ts := t.TrainingSet(selection)
+	if ts.selected {
+		t.model.Restrict(ts.Vocabulary)
+	}
+	return &NaiveBayes{TrainingSet: ts, Model: t.model, Selection: selection}
+}
+
+type StreamOptions struct {
+	Pipelines []Pipeline
+	// Models are trained on every pipeline, DefaultModels when empty. Only
+	// Naive Bayes models can be trained in one pass, the others are skipped.
+	Models []Model
+	// TrainRatio is the share of messages that goes to the training split
+	TrainRatio float64
+	// TestSample is the most test cases kept in memory, 0 keeps all
+	TestSample int
+	Seed       int64
+}
+
+// RunStream trains the Naive Bayes models on every pipeline in a single pass
+// over the records scan produces and tests them on the held out messages.
+// Messages are assigned to a split by parse.InTraining. The pipelines and
+// models that are not Streamable are skipped.
+func RunStream(scan func(fn func(parse.Record) error) error, opts StreamOptions) (Analyses, error) {
+	models := opts.Models
+	if len(models) == 0 {
+		models = DefaultModels()
+	}
+	type stream struct {
+		pipeline Pipeline
+		model    Model
+		nb       *NaiveBayes
+		trainer  *StreamTrainer
+	}
+	var streams []stream
+	for _, p := range opts.Pipelines {
+		for _, m := range models {
+			if !p.Streamable() || !m.Streamable() {
+				continue
+			}
+			nb := m.New().(*NaiveBayes)
+			trainer := NewStreamTrainer()
+			trainer.model = nb.newModel(TrainingSet{})
+			streams = append(streams, stream{pipeline: p, model: m, nb: nb, trainer: trainer})
+		}
+	}
+	reservoir := parse.NewReservoir(opts.TestSample, opts.Seed)
+
+	err := scan(func(r parse.Record) error {
+		if !parse.InTraining(r.Text, opts.TrainRatio) {
+			reservoir.Add(experiment.TestCase{Class: r.Class, Text: r.Text, Line: r.Line, Source: r.Source})
+			return nil
+		}
+		for _, s := range streams {
+			s.trainer.Add(r.Class, s.pipeline.processMessage(r.Text))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var analyses Analyses
+	for _, s := range streams {
+		var selection FeatureSelection
+		if s.pipeline.Selection != nil {
+			selection = *s.pipeline.Selection
+		}
+		nb := s.trainer.NaiveBayes(selection)
+		nb.Alpha, nb.Prior = s.nb.Alpha, s.nb.Prior
+		nb.TrainingSet.Alpha = s.nb.Alpha
+		test := experiment.TestSet{Cases: make([]experiment.TestCase, len(reservoir.Cases))}
+		for j, tc := range reservoir.Cases {
+			test.Cases[j] = experiment.TestCase{Class: tc.Class, Text: s.pipeline.processMessage(tc.Text), Line: tc.Line, Source: tc.Source}
+		}
+		analyses = append(analyses, Analysis{
+			Name:        s.pipeline.Name,
+			Model:       s.model.Name,
+			Classifier:  nb,
+			TrainingSet: nb.TrainingSet,
+			TestSet:     Evaluate(nb, test),
+		})
+	}
+	return analyses, nil
+}
+
+// Streamable tells whether RunStream can train on the pipeline, which needs
+// preprocessors that process a single message and no TF-IDF weighting, as
+// that needs the whole corpus.
+func (p Pipeline) Streamable() bool {
+	if p.TFIDF != nil {
+		return false
+	}
+	for _, pre := range p.Preprocessors {
+		if _, ok := pre.(MessagePreprocessor); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Streamable tells whether RunStream can train the model, which it can for
+// Naive Bayes only.
+func (m Model) Streamable() bool {
+	_, ok := m.New().(*NaiveBayes)
+	return ok
+}
+
+// processMessage runs a single message through the preprocessors of a
+// streamable pipeline.
+func (p Pipeline) processMessage(msg string) string {
+	for _, pre := range p.Preprocessors {
+		msg = pre.(MessagePreprocessor).ProcessMessage(msg)
+	}
+	return msg
+}
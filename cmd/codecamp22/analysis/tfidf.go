@@ -0,0 +1,70 @@
+package analysis
+
+import "math"
+
+// TFIDFOptions configures how TF-IDF weights are computed.
+type TFIDFOptions struct {
+	// Sublinear replaces a term frequency tf with 1 + log(tf), so a word
+	// repeated ten times does not count ten times as much.
+	Sublinear bool
+	// Smooth adds one to the number of documents and to every document
+	// frequency, as if one extra document contained every word once.
+	Smooth bool
+}
+
+// Weighted is implemented by classifiers that can weight their features with
+// TF-IDF instead of raw word counts.
+type Weighted interface {
+	UseTFIDF(opts TFIDFOptions)
+}
+
+// DocumentFrequency represents words and how many messages they occur in
+type DocumentFrequency map[string]int
+
+// TFIDF holds the document frequencies of a training corpus and weights the
+// words of a message by term frequency times inverse document frequency.
+type TFIDF struct {
+	Options           TFIDFOptions
+	Documents         int
+	DocumentFrequency DocumentFrequency
+}
+
+// NewTFIDF counts the document frequency of every word in the messages.
+func NewTFIDF(opts TFIDFOptions, messageLists ...[]string) *TFIDF {
+	t := &TFIDF{Options: opts, DocumentFrequency: make(DocumentFrequency)}
+	for _, messageList := range messageLists {
+		t.Documents += len(messageList)
+		for word, df := range documentFrequencyFrom(messageList) {
+			t.DocumentFrequency[word] += df
+		}
+	}
+	return t
+}
+
+// IDF returns the inverse document frequency of the word, or 0 for a word that
+// never occurred in the corpus.
+func (t *TFIDF) IDF(word string) float64 {
+	n, df := float64(t.Documents), float64(t.DocumentFrequency[word])
+	if t.Options.Smooth {
+		n, df = n+1, df+1
+	}
+	if df == 0 {
+		return 0
+	}
+	return math.Log(n/df) + 1
+}
+
+// Weights returns the TF-IDF weight of every distinct word in the message.
+func (t *TFIDF) Weights(msg string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, word := range words(msg) {
+		weights[word]++
+	}
+	for word, tf := range weights {
+		if t.Options.Sublinear {
+			tf = 1 + math.Log(tf)
+		}
+		weights[word] = tf * t.IDF(word)
+	}
+	return weights
+}
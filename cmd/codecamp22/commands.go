@@ -0,0 +1,871 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/feedback"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/runs"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/service"
+	"github.com/fatih/color"
+)
+
+// exitOn prints what could not be done and exits when err is set.
+func exitOn(err error, what string) {
+	if err != nil {
+		fmt.Println("cannot "+what+":", err)
+		os.Exit(1)
+	}
+}
+
+// runTrain handles `codecamp22 train`: the most discriminative words of every
+// pipeline are listed, counted on all of the data.
+func runTrain(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	cfg.selectionFlags(fs)
+	cfg.wordFlags(fs)
+	flagSave := fs.String("save", "", "also save a Naive Bayes model trained on all of the data to this file")
+	fs.Parse(args)
+
+	ranking, err := analysis.RankingType(cfg.Rank)
+	exitOn(err, "rank words")
+	pipelines, err := cfg.pipelines()
+	exitOn(err, "select features")
+	exp, report, err := cfg.load(false)
+	exitOn(err, "parse file")
+	printReport(report, cfg.Diagnostics)
+
+	for _, p := range pipelines {
+		a := analysis.Analysis{Name: p.Name, TrainingSet: p.TrainingSet(exp)}
+		printTrainingSet(a)
+		printDiscriminativeWords(a.TrainingSet, experiment.HamClass, cfg.Top, ranking)
+		fmt.Println("")
+		printDiscriminativeWords(a.TrainingSet, experiment.SpamClass, cfg.Top, ranking)
+		fmt.Println()
+	}
+	if *flagSave != "" {
+		selection, err := cfg.featureSelection()
+		exitOn(err, "select features")
+		nb := &analysis.NaiveBayes{Selection: selection}
+		nb.Train(exp.Classes)
+		exitOn(saveModel(nb.Model, *flagSave), "save model")
+		fmt.Println("Saved model to", *flagSave)
+	}
+	fmt.Println("\nDone.")
+}
+
+func saveModel(model *bayes.Classifier, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := model.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// examplesFrom returns the training messages as examples for the bayes package.
+func examplesFrom(classes experiment.Classes) []bayes.Example {
+	var examples []bayes.Example
+	for _, msg := range classes.Ham {
+		examples = append(examples, bayes.Example{Class: bayes.HamClass, Text: msg})
+	}
+	for _, msg := range classes.Spam {
+		examples = append(examples, bayes.Example{Class: bayes.SpamClass, Text: msg})
+	}
+	return examples
+}
+
+// runEval handles `codecamp22 eval`: every model is trained on part of the
+// data and tested on the rest. Settings can be read from a config file, see
+// readConfigFile, and flags given next to -config override them.
+func runEval(args []string) {
+	cfg, err := evalConfig(args, flag.ExitOnError)
+	exitOn(err, "evaluate")
+	evaluate(cfg)
+}
+
+// unstreamable names the pipelines and models eval -stream skips, empty when
+// it skips none.
+func unstreamable(pipelines []analysis.Pipeline, models []analysis.Model) string {
+	var skipped []string
+	for _, p := range pipelines {
+		if !p.Streamable() {
+			skipped = append(skipped, p.Name)
+		}
+	}
+	for _, m := range models {
+		if !m.Streamable() {
+			skipped = append(skipped, m.Name)
+		}
+	}
+	if len(skipped) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Skipping %s, which cannot be trained in a single pass", strings.Join(skipped, ", "))
+}
+
+// evalConfig parses the eval flags, reading the config file first when one
+// is given.
+func evalConfig(args []string, handling flag.ErrorHandling) (config, error) {
+	cfg := defaultConfig()
+	var configFile string
+	evalFlags := func(cfg *config) *flag.FlagSet {
+		fs := flag.NewFlagSet("eval", handling)
+		fs.StringVar(&configFile, "config", configFile, "experiment config file in YAML, JSON or TOML")
+		cfg.dataFlags(fs)
+		cfg.selectionFlags(fs)
+		cfg.splitFlags(fs)
+		cfg.evalFlags(fs)
+		return fs
+	}
+	if err := evalFlags(&cfg).Parse(args); err != nil {
+		return cfg, err
+	}
+	if configFile != "" {
+		cfg = defaultConfig()
+		if err := readConfigFile(configFile, &cfg); err != nil {
+			return cfg, err
+		}
+		// parse the flags again so they override the file
+		if err := evalFlags(&cfg).Parse(args); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.Output != "text" && cfg.Output != "json" {
+		return cfg, fmt.Errorf("invalid output format: %s", cfg.Output)
+	}
+	return cfg, nil
+}
+
+func evaluate(cfg config) {
+	opts, err := cfg.parseOptions()
+	exitOn(err, "parse file")
+	selection, err := cfg.featureSelection()
+	exitOn(err, "select features")
+	pipelines, err := cfg.pipelines()
+	exitOn(err, "select features")
+	models := cfg.models()
+	vocabSizes, err := parseSizes(cfg.VocabSizes)
+	exitOn(err, "parse vocabulary sizes")
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	if cfg.Stream {
+		opts.MaxSeen, opts.MaxDiagnostics = parse.StreamMaxSeen, parse.StreamMaxDiagnostics
+		var report parse.Report
+		analyses, err := analysis.RunStream(func(fn func(parse.Record) error) error {
+			var err error
+			report, err = parse.ScanFormat(cfg.File, opts, fn)
+			return err
+		}, analysis.StreamOptions{
+			Pipelines:  pipelines,
+			Models:     models,
+			TrainRatio: opts.TrainRatio,
+			TestSample: cfg.TestSample,
+			Seed:       seed,
+		})
+		exitOn(err, "stream file")
+		if skipped := unstreamable(pipelines, models); skipped != "" {
+			fmt.Fprintln(os.Stderr, skipped)
+		}
+		if cfg.Output == "json" {
+			exitOn(writeEvaluationJSON(os.Stdout, analyses, compareJSON(analyses, cfg.Baseline, cfg.Bootstrap, seed)), "write json")
+			recordRun(cfg, seed, analyses)
+			return
+		}
+		printReport(report, cfg.Diagnostics)
+		analyzeTestDataClassification(analyses)
+		analyzeSignificance(analyses, cfg.Baseline, cfg.Bootstrap, seed)
+		recordRun(cfg, seed, analyses)
+		fmt.Println("\nDone.")
+		return
+	}
+
+	//Duplicates are removed after the split, so the accuracy with and
+	//without them can be compared on the same split
+	dedup := opts.Dedup
+	opts.Dedup = parse.KeepDuplicates
+	opts.Split = true
+	opts.Seed = seed
+	exp, report, err := parse.LoadFile(cfg.File, opts)
+	exitOn(err, "parse file")
+
+	analyses := analysis.Run(exp, analysis.Options{Pipelines: pipelines, Models: models, Evaluate: true})
+	if cfg.Output == "json" {
+		exitOn(writeEvaluationJSON(os.Stdout, analyses, compareJSON(analyses, cfg.Baseline, cfg.Bootstrap, seed)), "write json")
+		recordRun(cfg, seed, analyses)
+		return
+	}
+	printReport(report, cfg.Diagnostics)
+	analyzeTestDataClassification(analyses)
+	analyzeSignificance(analyses, cfg.Baseline, cfg.Bootstrap, seed)
+	recordRun(cfg, seed, analyses)
+	if dedup != parse.KeepDuplicates {
+		analyzeDeduplication(exp, analyses, pipelines, models, dedup, cfg.NearDistance)
+	}
+	if len(vocabSizes) > 0 {
+		analyzeVocabularySizes(exp, pipelines, models, selection, vocabSizes)
+	}
+	fmt.Println("\nDone.")
+}
+
+// recordRun saves the results of eval in the run store of the config, telling
+// the ID of the run on stderr so it does not mix with JSON output.
+func recordRun(cfg config, seed int64, analyses analysis.Analyses) {
+	if cfg.Runs == "" {
+		return
+	}
+	dataset, err := runs.HashDataset(cfg.File)
+	exitOn(err, "record run")
+	run := runs.Run{Config: cfg.settings(), Seed: seed, Dataset: dataset}
+	for _, a := range analyses {
+		t := a.TestSet
+		run.Results = append(run.Results, runs.Result{
+			Analysis:      a.Name,
+			Model:         a.Model,
+			Vocabulary:    len(a.TrainingSet.Vocabulary),
+			TestMessages:  t.MessageTotal,
+			CorrectHam:    t.CorrectHam,
+			CorrectSpam:   t.CorrectSpam,
+			IncorrectHam:  t.IncorrectHam,
+			IncorrectSpam: t.IncorrectSpam,
+			Metrics:       runs.MetricsOf(t.CorrectHam, t.CorrectSpam, t.IncorrectHam, t.IncorrectSpam),
+		})
+	}
+	run, err = runs.Store{Dir: cfg.Runs}.Save(run)
+	exitOn(err, "record run")
+	fmt.Fprintf(os.Stderr, "Recorded run %s in %s\n", run.ID, cfg.Runs)
+}
+
+// runRuns handles `codecamp22 runs list` and `codecamp22 runs compare <id> <id>`.
+func runRuns(args []string) {
+	fs := flag.NewFlagSet("runs", flag.ExitOnError)
+	flagDir := fs.String("runs", runs.DefaultDir, "directory the runs are recorded in")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: codecamp22 runs [flags] list")
+		fmt.Fprintln(fs.Output(), "       codecamp22 runs [flags] compare <id> <id>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	store := runs.Store{Dir: *flagDir}
+
+	switch {
+	case fs.Arg(0) == "list" && fs.NArg() == 1:
+		list, err := store.List()
+		exitOn(err, "list runs")
+		if len(list) == 0 {
+			fmt.Println("No runs recorded in", store.Dir)
+		}
+		for _, run := range list {
+			best := runs.Result{}
+			for _, r := range run.Results {
+				if r.Accuracy > best.Accuracy {
+					best = r
+				}
+			}
+			fmt.Printf("%-20s %s  %v  dataset %.12s  best %.2f%% (%s, %s)\n", run.ID, run.Time.Format("2006-01-02 15:04"),
+				run.Config["file"], run.Dataset, best.Accuracy*100, best.Analysis, best.Model)
+		}
+	case fs.Arg(0) == "compare" && fs.NArg() == 3:
+		before, err := store.Load(fs.Arg(1))
+		exitOn(err, "compare runs")
+		after, err := store.Load(fs.Arg(2))
+		exitOn(err, "compare runs")
+		printComparison(before, after)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// printComparison lists the settings that changed between two runs and the
+// change in the metrics of every pipeline and model.
+func printComparison(before, after runs.Run) {
+	bold := color.New(color.Bold)
+	bold.Printf("Comparing run %s with run %s\n", before.ID, after.ID)
+	if before.Dataset != after.Dataset {
+		color.Yellow("The runs are on different datasets, %.12s and %.12s\n", before.Dataset, after.Dataset)
+	}
+	if changes := runs.ConfigChanges(before, after); len(changes) > 0 {
+		fmt.Println("Changed settings:")
+		for _, change := range changes {
+			fmt.Println("\t" + change)
+		}
+	} else {
+		fmt.Println("The settings are the same")
+	}
+
+	deltas := runs.Compare(before, after)
+	width := len("Pipeline / model")
+	for _, d := range deltas {
+		if n := len(d.Analysis + " / " + d.Model); n > width {
+			width = n
+		}
+	}
+	format := fmt.Sprintf("%%-%ds  %%22s %%22s %%22s %%22s\n", width)
+	fmt.Println()
+	bold.Printf(format, "Pipeline / model", "Accuracy", "Precision", "Recall", "F1")
+	for _, d := range deltas {
+		name := d.Analysis + " / " + d.Model
+		switch {
+		case d.Before == nil:
+			fmt.Printf("%-*s  only in %s\n", width, name, after.ID)
+		case d.After == nil:
+			fmt.Printf("%-*s  only in %s\n", width, name, before.ID)
+		default:
+			change := d.Change()
+			fmt.Printf(format, name,
+				metricChange(d.Before.Accuracy, d.After.Accuracy, change.Accuracy),
+				metricChange(d.Before.Precision, d.After.Precision, change.Precision),
+				metricChange(d.Before.Recall, d.After.Recall, change.Recall),
+				metricChange(d.Before.F1, d.After.F1, change.F1))
+		}
+	}
+}
+
+func metricChange(before, after, change float64) string {
+	return fmt.Sprintf("%.2f -> %.2f (%+.2f)", before*100, after*100, change*100)
+}
+
+// runMisclassified handles `codecamp22 misclassified`: every model is trained
+// on part of the data and the test cases it got wrong are listed, or written
+// to a CSV file.
+func runMisclassified(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("misclassified", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	cfg.selectionFlags(fs)
+	cfg.splitFlags(fs)
+	flagPipeline := fs.String("pipeline", "", "only list the mistakes of the pipeline with this name")
+	flagModel := fs.String("model", "", "only list the mistakes of this model, e.g. naive-bayes")
+	flagMistake := fs.String("mistake", analysis.AnyMistake.String(), "which mistakes to list: all, fp (ham classified as spam) or fn (spam classified as ham)")
+	flagConfidence := fs.Float64("min-confidence", 0, "only list mistakes the model was at least this sure of")
+	flagContains := fs.String("contains", "", "only list messages containing this text")
+	flagWords := fs.Int("words", 5, "number of words that misled the model to list for each mistake")
+	flagLimit := fs.Int("limit", 20, "the most mistakes to list (0 lists all)")
+	flagCSV := fs.String("csv", "", "write the mistakes to this CSV file instead of listing them")
+	fs.Parse(args)
+
+	mistake, err := analysis.MistakeType(*flagMistake)
+	exitOn(err, "list mistakes")
+	pipelines, err := cfg.pipelines()
+	exitOn(err, "select features")
+	if *flagPipeline != "" {
+		var selected []analysis.Pipeline
+		for _, p := range pipelines {
+			if strings.EqualFold(p.Name, *flagPipeline) {
+				selected = append(selected, p)
+			}
+		}
+		if len(selected) == 0 {
+			exitOn(fmt.Errorf("no pipeline named %q", *flagPipeline), "list mistakes")
+		}
+		pipelines = selected
+	}
+	models := cfg.models()
+	if *flagModel != "" {
+		m, err := analysis.ModelNamed(*flagModel)
+		exitOn(err, "list mistakes")
+		models = []analysis.Model{m}
+	}
+	exp, report, err := cfg.load(true)
+	exitOn(err, "parse file")
+
+	analyses := analysis.Run(exp, analysis.Options{Pipelines: pipelines, Models: models, Evaluate: true})
+	mistakes := analysis.Misclassified(exp, analyses, analysis.MisclassificationFilter{
+		Mistake:       mistake,
+		MinConfidence: *flagConfidence,
+		Contains:      *flagContains,
+		Words:         *flagWords,
+	})
+	if *flagCSV != "" {
+		exitOn(writeMisclassifiedCSV(*flagCSV, mistakes), "write csv")
+		fmt.Printf("Wrote %d mistakes to %s\n", len(mistakes), *flagCSV)
+		return
+	}
+	printReport(report, cfg.Diagnostics)
+	printMisclassified(mistakes, *flagLimit)
+}
+
+// runLearningCurve handles `codecamp22 learning-curve`: every model is trained
+// on growing fractions of the training split to show whether more data would
+// help.
+func runLearningCurve(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("learning-curve", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	cfg.selectionFlags(fs)
+	cfg.splitFlags(fs)
+	flagFractions := fs.String("fractions", "0.1,0.2,0.3,0.4,0.5,0.6,0.7,0.8,0.9,1", "comma separated shares of the training split to train on")
+	flagCSV := fs.String("csv", "", "also write the curves to this CSV file")
+	fs.Parse(args)
+
+	exitOn(cfg.testSplit(), "split data")
+	fractions, err := parseFractions(*flagFractions)
+	exitOn(err, "parse fractions")
+	pipelines, err := cfg.pipelines()
+	exitOn(err, "select features")
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	exp, report, err := cfg.load(true)
+	exitOn(err, "parse file")
+	printReport(report, cfg.Diagnostics)
+
+	var curves []learningCurve
+	for _, p := range pipelines {
+		for _, m := range cfg.models() {
+			curve := learningCurve{Pipeline: p.Name, Model: m.Name, Points: analysis.LearningCurve(exp, p, m, fractions, cfg.Seed)}
+			printLearningCurve(curve)
+			curves = append(curves, curve)
+		}
+	}
+	if *flagCSV != "" {
+		exitOn(writeLearningCurvesCSV(*flagCSV, curves), "write csv")
+		fmt.Println("Wrote the learning curves to", *flagCSV)
+	}
+}
+
+// runSearch handles `codecamp22 search`: combinations of Naive Bayes
+// hyperparameters are scored by cross validation, and the best one can be
+// saved as a trained model.
+func runSearch(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed of the folds and the random search (0 is random)")
+	fs.StringVar(&cfg.Select, "select", cfg.Select, "how to rank words for the vocabulary cutoffs: llr, chi2 or mi")
+	flagPipelines := fs.String("pipelines", "", "comma separated names of the pipelines to try (default all)")
+	flagAlphas := fs.String("alphas", "0.1,0.5,1", "comma separated smoothings to try")
+	flagNGrams := fs.String("ngrams", "1,2", "comma separated longest word sequences to try, 1 for single words")
+	flagMinDFs := fs.String("min-dfs", "0", "comma separated minimum document frequencies to try")
+	flagMaxVocabs := fs.String("max-vocabs", "0", "comma separated vocabulary caps to try (0 keeps all)")
+	flagPriors := fs.String("priors", "uniform,class", "comma separated priors to try: uniform or class")
+	flagRandom := fs.Int("random", 0, "try this many random combinations instead of all of them")
+	flagFolds := fs.Int("folds", 5, "number of cross validation folds")
+	flagTop := fs.Int("top", 10, "number of best combinations to list")
+	flagSave := fs.String("save", "", "save a Naive Bayes model trained on all of the data with the best combination to this file")
+	fs.Parse(args)
+
+	ranking, err := analysis.RankingType(cfg.Select)
+	exitOn(err, "rank words")
+	space := analysis.SearchSpace{Ranking: ranking}
+	space.Pipelines, err = pipelinesNamed(*flagPipelines)
+	exitOn(err, "search")
+	space.Alphas, err = parseFloats(*flagAlphas)
+	exitOn(err, "parse alphas")
+	space.NGrams, err = parseSizes(*flagNGrams)
+	exitOn(err, "parse n-grams")
+	space.MinDFs, err = parseCounts(*flagMinDFs)
+	exitOn(err, "parse minimum document frequencies")
+	space.MaxVocabularies, err = parseCounts(*flagMaxVocabs)
+	exitOn(err, "parse vocabulary caps")
+	for _, name := range strings.Split(*flagPriors, ",") {
+		prior, err := analysis.PriorType(strings.TrimSpace(name))
+		exitOn(err, "parse priors")
+		space.Priors = append(space.Priors, prior)
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	exp, report, err := cfg.load(false)
+	exitOn(err, "parse file")
+	printReport(report, cfg.Diagnostics)
+	var cases []experiment.TestCase
+	for _, msg := range exp.Classes.Ham {
+		cases = append(cases, experiment.TestCase{Class: experiment.HamClass, Text: msg})
+	}
+	for _, msg := range exp.Classes.Spam {
+		cases = append(cases, experiment.TestCase{Class: experiment.SpamClass, Text: msg})
+	}
+
+	candidates := space.Random(*flagRandom, cfg.Seed)
+	fmt.Printf("Trying %d combinations with %d fold cross validation\n\n", len(candidates), *flagFolds)
+	results := analysis.Search(cases, candidates, *flagFolds, cfg.Seed)
+	printSearchResults(results, *flagTop)
+
+	if *flagSave != "" {
+		if len(results) == 0 {
+			exitOn(fmt.Errorf("no combinations were tried"), "save model")
+		}
+		best := results[0].Candidate
+		model, err := searchModel(best, exp.Classes)
+		exitOn(err, "save model")
+		exitOn(saveModel(model, *flagSave), "save model")
+		fmt.Printf("Saved a model with %s to %s\n", best, *flagSave)
+	}
+}
+
+// pipelinesNamed returns the default pipelines with the comma separated
+// names, ignoring case, or all of them when names is empty.
+func pipelinesNamed(names string) ([]analysis.Pipeline, error) {
+	if strings.TrimSpace(names) == "" {
+		return analysis.DefaultPipelines(), nil
+	}
+	var pipelines []analysis.Pipeline
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, p := range analysis.DefaultPipelines() {
+			if strings.EqualFold(p.Name, name) {
+				pipelines = append(pipelines, p)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no pipeline named %q", name)
+		}
+	}
+	return pipelines, nil
+}
+
+// searchModel trains the Naive Bayes model of the candidate on the classes.
+// It fails when the model cannot be saved: with TF-IDF weights, or a
+// preprocessor that has no name or cannot process a single message.
+func searchModel(c analysis.Candidate, classes experiment.Classes) (*bayes.Classifier, error) {
+	pipeline := c.WithFeatures()
+	if pipeline.TFIDF != nil {
+		return nil, fmt.Errorf("the best combination, %s, weights words with TF-IDF, which a saved model cannot", c)
+	}
+	var names []string
+	for _, pre := range pipeline.Preprocessors {
+		name, ok := preprocessorName(pre)
+		if _, single := pre.(analysis.MessagePreprocessor); !ok || !single {
+			return nil, fmt.Errorf("the best combination, %s, has a preprocessor a saved model cannot apply to single messages", c)
+		}
+		names = append(names, name)
+	}
+	ex := experiment.Experiment{Classes: classes}.Copy()
+	for _, pre := range pipeline.Preprocessors {
+		pre.Process(&ex)
+	}
+	nb := &analysis.NaiveBayes{Alpha: c.Alpha, Prior: c.Prior, Preprocessors: names}
+	if pipeline.Selection != nil {
+		nb.UseFeatureSelection(*pipeline.Selection)
+	}
+	nb.Train(ex.Classes)
+	return nb.Model, nil
+}
+
+// preprocessorName returns the name a config file gives the preprocessor.
+func preprocessorName(pre analysis.Preprocessor) (string, bool) {
+	for name, named := range preprocessors {
+		if named == pre {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// runBench handles `codecamp22 bench`: every model is trained on every
+// pipeline and classifies the test split, on the data and on synthetic
+// corpora made up from its words, timing both.
+func runBench(args []string) {
+	cfg := defaultConfig()
+	cfg.Seed = 1
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	cfg.selectionFlags(fs)
+	cfg.splitFlags(fs)
+	flagScales := fs.String("scales", "1,4", "comma separated corpus sizes as multiples of the data; 1 is the data itself, more are synthetic corpora")
+	flagOutput := fs.String("output", "text", "output format of the measurements: text or json")
+	fs.Parse(args)
+
+	scales, err := parseSizes(*flagScales)
+	exitOn(err, "parse scales")
+	if *flagOutput != "text" && *flagOutput != "json" {
+		exitOn(fmt.Errorf("invalid output format: %s", *flagOutput), "benchmark")
+	}
+	pipelines, err := cfg.pipelines()
+	exitOn(err, "select features")
+	exp, report, err := cfg.load(true)
+	exitOn(err, "parse file")
+	if *flagOutput == "text" {
+		printReport(report, cfg.Diagnostics)
+	}
+
+	var results []benchResult
+	for _, scale := range scales {
+		corpus := exp
+		if scale > 1 {
+			corpus = analysis.Synthesize(exp, scale, cfg.Seed)
+		}
+		var measurements []analysis.Measurement
+		for _, p := range pipelines {
+			for _, m := range cfg.models() {
+				measurements = append(measurements, analysis.Measure(corpus, p, m))
+			}
+		}
+		if *flagOutput == "text" {
+			printMeasurements(scale, measurements)
+		}
+		results = append(results, benchResult{Scale: scale, Measurements: measurements})
+	}
+	if *flagOutput == "json" {
+		exitOn(writeBenchJSON(os.Stdout, results), "write json")
+	}
+}
+
+// defaultMessage is classified when no message is given.
+const defaultMessage = "u have me and im in love with u 2"
+
+// runClassify handles `codecamp22 classify [message]`: every model is
+// trained on all of the data and classifies the message, or a saved model
+// does.
+func runClassify(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("classify", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	cfg.selectionFlags(fs)
+	flagExplain := fs.Bool("explain", false, "list the contribution of every word to the classification")
+	flagOutput := fs.String("output", "text", "output format of the classification: text or json")
+	flagModel := fs.String("model", "", "classify with the model saved by train -save instead of training")
+	fs.Parse(args)
+
+	message := strings.Join(fs.Args(), " ")
+	if message == "" {
+		message = defaultMessage
+	}
+	if *flagModel != "" {
+		classifyWithModel(*flagModel, message, *flagOutput)
+		return
+	}
+	pipelines, err := cfg.pipelines()
+	exitOn(err, "select features")
+	exp, report, err := cfg.load(false)
+	exitOn(err, "parse file")
+	exp.TextMessage = message
+
+	analyses := analysis.Run(exp, analysis.Options{Pipelines: pipelines})
+	if *flagOutput == "json" {
+		exitOn(writeExplanationsJSON(os.Stdout, analyses, *flagExplain), "write json")
+		return
+	}
+	printReport(report, cfg.Diagnostics)
+	analyzeTextMessageClassification(analyses, message, *flagExplain)
+	fmt.Println("\nDone.")
+}
+
+func classifyWithModel(path, message, output string) {
+	file, err := os.Open(path)
+	exitOn(err, "load model")
+	model, err := bayes.Load(file, bayes.Options{})
+	file.Close()
+	exitOn(err, "load model")
+	processed := message
+	for _, name := range model.Preprocessors() {
+		pre, exists := preprocessors[name].(analysis.MessagePreprocessor)
+		if !exists {
+			exitOn(fmt.Errorf("unknown preprocessor %q", name), "load model")
+		}
+		processed = pre.ProcessMessage(processed)
+	}
+
+	result := struct {
+		Class           bayes.Class `json:"class"`
+		SpamProbability float64     `json:"spamProbability"`
+	}{model.Classify(processed), model.SpamProbability(processed)}
+	if output == "json" {
+		exitOn(json.NewEncoder(os.Stdout).Encode(result), "write json")
+		return
+	}
+	boldRed := color.New(color.FgRed, color.Bold)
+	boldRed.Printf("Text Message: ")
+	fmt.Println(message)
+	boldRed.Printf("Classifies as: ")
+	fmt.Println(result.Class)
+	boldRed.Printf("Spam probability: ")
+	fmt.Printf("%.4f\n", result.SpamProbability)
+}
+
+// runFeedback handles `codecamp22 feedback -label spam "message"`: the
+// message is appended to the feedback store and learned by the model.
+func runFeedback(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("feedback", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	flagStore := fs.String("store", "feedback.data", "file the corrected messages are appended to")
+	flagLabel := fs.String("label", "", "correct label of the message: ham or spam")
+	fs.Parse(args)
+
+	label, err := bayes.ClassType(*flagLabel)
+	exitOn(err, "give feedback")
+	text := strings.Join(fs.Args(), " ")
+	store := feedback.Store{Path: *flagStore, Delimiter: cfg.Delimiter}
+
+	nb, err := trainOnline(cfg, store)
+	exitOn(err, "train")
+	before := nb.Classify(text)
+	text, err = store.Append(label, text)
+	exitOn(err, "store feedback")
+	exitOn(nb.Learn(label, text), "learn feedback")
+
+	boldRed := color.New(color.FgRed, color.Bold)
+	boldRed.Printf("Text Message: ")
+	fmt.Println(text)
+	boldRed.Printf("Classified as: ")
+	fmt.Println(before)
+	boldRed.Printf("Labeled as: ")
+	fmt.Println(label)
+	boldRed.Printf("Now classifies as: ")
+	fmt.Println(nb.Classify(text))
+	fmt.Println("\nStored in", store.Path)
+}
+
+// runServe handles `codecamp22 serve`, the HTTP classification service.
+func runServe(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	flagStore := fs.String("store", "feedback.data", "file the corrected messages are appended to")
+	flagAddr := fs.String("addr", ":8080", "address to listen on")
+	fs.Parse(args)
+
+	store := feedback.Store{Path: *flagStore, Delimiter: cfg.Delimiter}
+	nb, err := trainOnline(cfg, store)
+	exitOn(err, "train")
+	fmt.Println("Listening on", *flagAddr)
+	exitOn(http.ListenAndServe(*flagAddr, service.New(nb, store).Handler()), "serve")
+}
+
+// runStats handles `codecamp22 stats`, which describes a dataset.
+func runStats(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	cfg.Top = 10
+	fs.Var((*count)(&cfg.Top), "top", "`number` of most frequent words to list")
+	fs.Parse(args)
+
+	exp, report, err := cfg.load(true)
+	exitOn(err, "parse file")
+	printReport(report, cfg.Diagnostics)
+	stats := analysis.Describe(exp, analysis.DefaultPipelines(), cfg.Top)
+
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Println("Classes")
+	total := len(exp.Classes.Ham) + len(exp.Classes.Spam) + len(exp.Test.Cases)
+	fmt.Printf("\t%-6s %8s %8s   %-34s %s\n", "Class", "Messages", "Share", "Characters (min/median/mean/p90/max)", "Words (min/median/mean/p90/max)")
+	for _, cs := range stats.Classes {
+		fmt.Printf("\t%-6s %8d %7.2f%%   %-34s %s\n", cs.Class, cs.Messages, percent(cs.Messages, total),
+			formatLengths(cs.Characters), formatLengths(cs.Words))
+	}
+
+	c.Println("\nWord frequencies")
+	z := stats.Zipf
+	fmt.Printf("\t%d words, %d distinct, %d occur only once (%.2f%%)\n", z.Tokens, z.Types, z.Hapax, 100*z.HapaxRate())
+	fmt.Printf("\tZipf exponent %.2f, the top 10 words are %.2f%% and the top 100 %.2f%% of all words\n", z.Exponent, 100*z.Top10Share, 100*z.Top100Share)
+	for i, wc := range z.Top {
+		fmt.Printf("\t%4d. %-20s %d\n", i+1, wc.Word, wc.Count)
+	}
+
+	c.Println("\nVocabulary per pipeline")
+	fmt.Printf("\t%-40s %10s %12s\n", "Pipeline", "Words", "Test OOV")
+	for _, ps := range stats.Pipelines {
+		fmt.Printf("\t%-40s %10d %11.2f%%\n", ps.Name, ps.Vocabulary, 100*ps.OutOfVocabularyRate())
+	}
+
+	c.Println("\nLabel conflicts")
+	conflicts := 0
+	for _, d := range report.Diagnostics {
+		if d.Issue == parse.LabelConflict {
+			fmt.Println("\t" + d.Error())
+			conflicts++
+		}
+	}
+	if report.LabelConflict == 0 {
+		fmt.Println("\tnone")
+	} else if conflicts < report.LabelConflict {
+		fmt.Printf("\tand %d more\n", report.LabelConflict-conflicts)
+	}
+}
+
+// percent is n as a percentage of total, 0 when there is nothing to count.
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func formatLengths(l analysis.Lengths) string {
+	return fmt.Sprintf("%d/%d/%.1f/%d/%d", l.Min, l.Median, l.Mean, l.P90, l.Max)
+}
+
+// runNoise handles `codecamp22 noise`, which lists the labeled messages that
+// cross validated predictions confidently disagree with, for human review.
+func runNoise(args []string) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet("noise", flag.ExitOnError)
+	cfg.dataFlags(fs)
+	flagModel := fs.String("model", "naive-bayes", "model to predict with: naive-bayes or logistic-regression")
+	flagFolds := fs.Int("folds", 5, "number of cross validation folds, at least 2")
+	flagConfidence := fs.Float64("confidence", 0.9, "least probability of the other class for a message to be suspect")
+	flagTop := fs.Int("top", 50, "most suspects to list (0 lists all)")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed of the assignment of messages to folds (0 is random)")
+	fs.Parse(args)
+
+	if *flagFolds < 2 {
+		exitOn(fmt.Errorf("invalid folds: must be at least 2, got %d", *flagFolds), "cross validate")
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	opts, err := cfg.parseOptions()
+	exitOn(err, "parse file")
+	model, err := analysis.ModelNamed(*flagModel)
+	exitOn(err, "find model")
+	var cases []experiment.TestCase
+	report, err := parse.ScanFormat(cfg.File, opts, func(r parse.Record) error {
+		cases = append(cases, experiment.TestCase{Class: r.Class, Text: r.Text, Line: r.Line, Source: r.Source})
+		return nil
+	})
+	exitOn(err, "parse file")
+	printReport(report, cfg.Diagnostics)
+
+	pipeline := analysis.DefaultPipelines()[0]
+	suspects := analysis.LabelNoise(cases, pipeline, model, *flagFolds, cfg.Seed, *flagConfidence)
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Printf("%d of %d messages may be mislabeled (%s, %d folds, confidence %.2f)\n",
+		len(suspects), len(cases), model.Name, *flagFolds, *flagConfidence)
+	if *flagTop > 0 && len(suspects) > *flagTop {
+		suspects = suspects[:*flagTop]
+	}
+	fmt.Printf("\t%-12s %-6s %-10s %10s  %s\n", "Location", "Label", "Predicted", "Confidence", "Text")
+	for _, s := range suspects {
+		fmt.Printf("\t%-12s %-6s %-10s %10.4f  %s\n", s.Location(), s.Label, s.Predicted, s.Confidence, s.Text)
+	}
+}
+
+// trainOnline trains Naive Bayes on all of the data plus the messages
+// already in the feedback store.
+func trainOnline(cfg config, store feedback.Store) (*bayes.Classifier, error) {
+	exp, _, err := cfg.load(false)
+	if err != nil {
+		return nil, err
+	}
+	corrected, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	nb := bayes.New(bayes.Options{})
+	nb.Train(append(examplesFrom(exp.Classes), corrected...))
+	return nb, nil
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/runs"
+)
+
+// config holds the settings the subcommands share. Every subcommand registers
+// the flags it needs on its own flag set, all writing into one config that is
+// then passed on explicitly.
+type config struct {
+	// File is the dataset, read in Format
+	File        string
+	Format      string
+	Delimiter   string
+	LabelColumn string
+	TextColumn  string
+	Header      bool
+	Strict      bool
+	// Diagnostics lists every skipped or duplicated line of the dataset
+	Diagnostics bool
+	// Dedup removes repeated messages: none, exact or near
+	Dedup        string
+	NearDistance int
+
+	// MinDF, MaxVocab and Select limit the vocabulary of the models
+	MinDF    int
+	MaxVocab int
+	Select   string
+
+	// Top and Rank choose the discriminative words that are listed
+	Top  int
+	Rank string
+
+	// Stream evaluates in a single pass over the dataset, keeping at most
+	// TestSample test messages in memory
+	Stream     bool
+	TestSample int
+	// VocabSizes are the vocabulary caps eval reports the accuracy for
+	VocabSizes string
+	// TrainRatio is the share of messages eval trains on, and Seed the seed
+	// they are shuffled with before the split, random when zero
+	TrainRatio float64
+	Seed       int64
+	// Output is the format eval reports in: text or json
+	Output string
+	// Baseline is the pipeline eval tests the others against, the first one
+	// when empty, with Bootstrap resamples for the confidence intervals
+	Baseline  string
+	Bootstrap int
+	// Runs is the directory eval records its runs in, none when empty
+	Runs string
+
+	// Pipelines and Models are compared by eval, the default ones when empty.
+	// They can only be set in a config file.
+	Pipelines []analysis.Pipeline
+	Models    []analysis.Model
+}
+
+func defaultConfig() config {
+	return config{
+		File:         "trainingData.data",
+		Format:       parse.Delimited.String(),
+		Delimiter:    "\t",
+		LabelColumn:  "0",
+		TextColumn:   "1",
+		Dedup:        parse.KeepDuplicates.String(),
+		NearDistance: parse.DefaultNearDistance,
+		Select:       analysis.ChiSquare.String(),
+		Top:          5,
+		Rank:         analysis.LogLikelihoodRatio.String(),
+		TrainRatio:   parse.DefaultTrainRatio,
+		TestSample:   parse.StreamTestSample,
+		Output:       "text",
+		Bootstrap:    analysis.DefaultBootstrapSamples,
+	}
+}
+
+// dataFlags registers the flags that tell where the dataset is and how to read it.
+func (c *config) dataFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.File, "file", c.File, "filename")
+	fs.StringVar(&c.Delimiter, "delimiter", c.Delimiter, "delimiter between class and words in data (default is tab)")
+	fs.StringVar(&c.Format, "format", c.Format, "format of the data: delimited, csv, jsonl or mbox (a directory with ham and spam folders)")
+	fs.StringVar(&c.LabelColumn, "label-column", c.LabelColumn, "csv column of the label, by index or header name")
+	fs.StringVar(&c.TextColumn, "text-column", c.TextColumn, "csv column of the text, by index or header name")
+	fs.BoolVar(&c.Header, "header", c.Header, "the first csv row holds column names")
+	fs.BoolVar(&c.Strict, "strict", c.Strict, "stop at the first invalid line of the data instead of skipping it")
+	fs.BoolVar(&c.Diagnostics, "diagnostics", c.Diagnostics, "list every line of the data that was skipped or duplicated")
+	fs.StringVar(&c.Dedup, "dedup", c.Dedup, "remove repeated messages: none, exact or near (similar SimHash)")
+	fs.IntVar(&c.NearDistance, "near-distance", c.NearDistance, "with -dedup near, the most SimHash bits in which near duplicates differ")
+}
+
+// selectionFlags registers the flags that limit the vocabulary.
+func (c *config) selectionFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.MinDF, "min-df", c.MinDF, "drop words that occur in fewer training messages")
+	fs.IntVar(&c.MaxVocab, "max-vocab", c.MaxVocab, "keep only this many of the highest ranked words (0 keeps all)")
+	fs.StringVar(&c.Select, "select", c.Select, "how to rank words when capping the vocabulary: llr, chi2 or mi")
+}
+
+// wordFlags registers the flags that choose the discriminative words listed.
+func (c *config) wordFlags(fs *flag.FlagSet) {
+	fs.Var((*count)(&c.Top), "top", "`number` of most discriminative words to show for each class")
+	fs.StringVar(&c.Rank, "rank", c.Rank, "how to rank discriminative words: llr, chi2 or mi")
+}
+
+// count is an int flag that cannot be negative.
+type count int
+
+func (c *count) String() string {
+	return strconv.Itoa(int(*c))
+}
+
+func (c *count) Set(s string) error {
+	n, err := strconv.ParseInt(s, 0, strconv.IntSize)
+	if err != nil {
+		return errors.New("parse error")
+	}
+	if n < 0 {
+		return fmt.Errorf("must not be negative, got %d", n)
+	}
+	*c = count(n)
+	return nil
+}
+
+// splitFlags registers the flags that hold out part of the dataset for testing.
+func (c *config) splitFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.TrainRatio, "train-ratio", c.TrainRatio, "share of the messages to train on")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "seed of the shuffle before the split (0 is random)")
+}
+
+// testSplit checks that the split leaves messages to test on, for the commands
+// that have nothing to report without them.
+func (c config) testSplit() error {
+	if c.TrainRatio >= 1 {
+		return fmt.Errorf("invalid train ratio: must be less than 1 to leave messages to test on, got %g", c.TrainRatio)
+	}
+	return nil
+}
+
+// evalFlags registers the flags of the evaluation on a held out split.
+func (c *config) evalFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.Stream, "stream", c.Stream, "evaluate in a single streaming pass over the file, for corpora too large for memory")
+	fs.IntVar(&c.TestSample, "test-sample", c.TestSample, "with -stream, the most test messages kept in memory (0 keeps all)")
+	fs.StringVar(&c.VocabSizes, "vocab-sizes", c.VocabSizes, "comma separated vocabulary caps to report accuracy for, e.g. 100,1000,5000")
+	fs.StringVar(&c.Output, "output", c.Output, "output format of the evaluation: text or json")
+	fs.StringVar(&c.Baseline, "baseline", c.Baseline, "pipeline the others are tested for significant differences against (default the first)")
+	fs.IntVar(&c.Bootstrap, "bootstrap", c.Bootstrap, "resamples for the bootstrap confidence intervals of the accuracy differences")
+	fs.StringVar(&c.Runs, "runs", c.Runs, "directory to record the run in for runs compare, e.g. "+runs.DefaultDir+" (default records nothing)")
+}
+
+func (c config) parseOptions() (parse.Options, error) {
+	format, err := parse.FormatType(c.Format)
+	if err != nil {
+		return parse.Options{}, err
+	}
+	dedup, err := parse.DedupType(c.Dedup)
+	if err != nil {
+		return parse.Options{}, err
+	}
+	if c.TrainRatio <= 0 || c.TrainRatio > 1 {
+		return parse.Options{}, fmt.Errorf("invalid train ratio: must be more than 0 and at most 1, got %g", c.TrainRatio)
+	}
+	return parse.Options{
+		Format:       format,
+		Delimiter:    c.Delimiter,
+		LabelColumn:  c.LabelColumn,
+		TextColumn:   c.TextColumn,
+		Header:       c.Header,
+		Strict:       c.Strict,
+		Dedup:        dedup,
+		NearDistance: c.NearDistance,
+		TrainRatio:   c.TrainRatio,
+		Seed:         c.Seed,
+	}, nil
+}
+
+func (c config) featureSelection() (analysis.FeatureSelection, error) {
+	ranking, err := analysis.RankingType(c.Select)
+	if err != nil {
+		return analysis.FeatureSelection{}, err
+	}
+	return analysis.FeatureSelection{
+		MinDocumentFrequency: c.MinDF,
+		MaxVocabulary:        c.MaxVocab,
+		Ranking:              ranking,
+	}, nil
+}
+
+// pipelines returns the configured pipelines, or the default pipelines with
+// their vocabulary limited when a feature selection is configured.
+func (c config) pipelines() ([]analysis.Pipeline, error) {
+	if len(c.Pipelines) > 0 {
+		return c.Pipelines, nil
+	}
+	selection, err := c.featureSelection()
+	if err != nil {
+		return nil, err
+	}
+	pipelines := analysis.DefaultPipelines()
+	if selection.MinDocumentFrequency > 0 || selection.MaxVocabulary > 0 {
+		for i := range pipelines {
+			pipelines[i].Selection = &selection
+		}
+	}
+	return pipelines, nil
+}
+
+func (c config) models() []analysis.Model {
+	if len(c.Models) > 0 {
+		return c.Models
+	}
+	return analysis.DefaultModels()
+}
+
+// load reads the dataset. With split set, part of it is held out as test cases.
+func (c config) load(split bool) (experiment.Experiment, parse.Report, error) {
+	opts, err := c.parseOptions()
+	if err != nil {
+		return experiment.Experiment{}, parse.Report{}, err
+	}
+	opts.Split = split
+	return parse.LoadFile(c.File, opts)
+}
+
+// settings returns the settings that change the results of eval, by the names
+// of their keys in a config file, for the run store.
+func (c config) settings() map[string]interface{} {
+	settings := map[string]interface{}{
+		"file":        c.File,
+		"format":      c.Format,
+		"dedup":       c.Dedup,
+		"train_ratio": c.TrainRatio,
+		"stream":      c.Stream,
+	}
+	if c.Format == parse.Delimited.String() {
+		settings["delimiter"] = c.Delimiter
+	}
+	if c.Format == parse.CSV.String() {
+		settings["label_column"] = c.LabelColumn
+		settings["text_column"] = c.TextColumn
+		settings["header"] = c.Header
+	}
+	if c.Dedup == parse.RemoveNear.String() {
+		settings["near_distance"] = c.NearDistance
+	}
+	if c.Stream {
+		settings["test_sample"] = c.TestSample
+	}
+	if len(c.Pipelines) == 0 {
+		settings["min_df"] = c.MinDF
+		settings["max_vocab"] = c.MaxVocab
+		settings["rank"] = c.Select
+	}
+	var pipelines, models []interface{}
+	if all, err := c.pipelines(); err == nil {
+		for _, p := range all {
+			pipelines = append(pipelines, pipelineSettings(p))
+		}
+	}
+	for _, m := range c.models() {
+		models = append(models, modelSettings(m))
+	}
+	settings["pipelines"] = pipelines
+	settings["classifiers"] = models
+	return settings
+}
+
+// pipelineSettings describes the pipeline by the keys of a config file.
+func pipelineSettings(p analysis.Pipeline) map[string]interface{} {
+	settings := map[string]interface{}{"name": p.Name}
+	var names []string
+	for _, pre := range p.Preprocessors {
+		name, ok := preprocessorName(pre)
+		if !ok {
+			name = fmt.Sprintf("%T", pre)
+		}
+		names = append(names, name)
+	}
+	if names != nil {
+		settings["preprocessors"] = names
+	}
+	if p.TFIDF != nil {
+		settings["tfidf"] = map[string]interface{}{"sublinear": p.TFIDF.Sublinear, "smooth": p.TFIDF.Smooth}
+	}
+	if p.Selection != nil {
+		settings["selection"] = map[string]interface{}{
+			"min_df":    p.Selection.MinDocumentFrequency,
+			"max_vocab": p.Selection.MaxVocabulary,
+			"rank":      p.Selection.Ranking.String(),
+		}
+	}
+	return settings
+}
+
+// modelSettings describes the model by the keys of a config file, with the
+// values it trains with.
+func modelSettings(m analysis.Model) map[string]interface{} {
+	settings := map[string]interface{}{"model": strings.ToLower(strings.ReplaceAll(m.Name, " ", "-"))}
+	switch c := m.New().(type) {
+	case *analysis.LogisticRegression:
+		settings["learning_rate"], settings["lambda"], settings["epochs"] = c.Hyperparameters()
+		settings["seed"] = c.Seed
+	case *analysis.NaiveBayes:
+		settings["alpha"] = c.Alpha
+		if c.Alpha <= 0 {
+			settings["alpha"] = 1.0
+		}
+		settings["prior"] = c.Prior.String()
+	}
+	return settings
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigFile reads an experiment config file on top of cfg. The format is
+// YAML, JSON or TOML by the extension of the file. Every section and key is
+// optional:
+//
+//	dataset:
+//	  file: trainingData.data
+//	  format: delimited          # delimited, csv, jsonl or mbox
+//	  delimiter: "\t"
+//	  label_column: 0            # csv column by index or header name
+//	  text_column: 1
+//	  header: false
+//	  strict: false
+//	  dedup: none                # none, exact or near
+//	  near_distance: 3
+//	split:
+//	  train_ratio: 0.75
+//	  seed: 1                    # 0 splits differently every run
+//	  stream: false
+//	  test_sample: 10000         # test messages kept when streaming, 0 keeps all
+//	selection:                   # applies to the default pipelines
+//	  min_df: 0
+//	  max_vocab: 0
+//	  rank: chi2                 # llr, chi2 or mi
+//	pipelines:                   # the default pipelines when left out
+//	  - name: Stemmed
+//	    preprocessors: [stemmer, no-punctuation, no-common-words, bigrams]
+//	    tfidf: {sublinear: true, smooth: true}
+//	    selection: {min_df: 2, max_vocab: 1000, rank: chi2}
+//	classifiers:                 # the default models when left out
+//	  - model: naive-bayes
+//	    alpha: 1                 # additive smoothing of the word counts
+//	    prior: uniform           # uniform or class
+//	  - model: logistic-regression
+//	    learning_rate: 0.1
+//	    lambda: 0.0001
+//	    epochs: 10
+//	    seed: 1
+//	output:
+//	  format: text               # text or json
+//	  vocab_sizes: [100, 1000]
+//	  baseline: Stemmed          # pipeline the others are tested against
+//	  bootstrap: 1000            # resamples for the confidence intervals
+//	  runs: .codecamp22/runs      # where runs are recorded, none when unset
+//
+// Errors name the offending key, e.g. "pipelines[1].tfidf.smooth".
+func readConfigFile(path string, cfg *config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	var root interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &root)
+	case ".json":
+		err = json.Unmarshal(data, &root)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		root = table
+	default:
+		return fmt.Errorf("reading %s: unknown config format %q, use .yaml, .json or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	if root == nil {
+		return nil
+	}
+	if err := cfg.decode(value{v: root}); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *config) decode(root value) error {
+	return root.object(fields{
+		"dataset": func(v value) error {
+			return v.object(fields{
+				"file":          v.stringTo(&c.File),
+				"format":        v.oneOf(&c.Format, parse.Delimited, parse.CSV, parse.JSONLines, parse.Mbox),
+				"delimiter":     v.stringTo(&c.Delimiter),
+				"label_column":  v.columnTo(&c.LabelColumn),
+				"text_column":   v.columnTo(&c.TextColumn),
+				"header":        v.boolTo(&c.Header),
+				"strict":        v.boolTo(&c.Strict),
+				"dedup":         v.oneOf(&c.Dedup, parse.KeepDuplicates, parse.RemoveExact, parse.RemoveNear),
+				"near_distance": v.countTo(&c.NearDistance),
+			})
+		},
+		"split": func(v value) error {
+			return v.object(fields{
+				"train_ratio": func(v value) error {
+					ratio, err := v.number()
+					if err == nil && (ratio <= 0 || ratio > 1) {
+						err = v.errorf("must be more than 0 and at most 1, got %g", ratio)
+					}
+					c.TrainRatio = ratio
+					return err
+				},
+				"seed": func(v value) error {
+					seed, err := v.integer()
+					c.Seed = int64(seed)
+					return err
+				},
+				"stream":      v.boolTo(&c.Stream),
+				"test_sample": v.countTo(&c.TestSample),
+			})
+		},
+		"selection": func(v value) error {
+			return v.object(fields{
+				"min_df":    v.countTo(&c.MinDF),
+				"max_vocab": v.countTo(&c.MaxVocab),
+				"rank":      v.oneOf(&c.Select, analysis.LogLikelihoodRatio, analysis.ChiSquare, analysis.MutualInformation),
+			})
+		},
+		"pipelines": func(v value) error {
+			c.Pipelines = nil
+			return v.list(func(v value) error {
+				p, err := decodePipeline(v)
+				c.Pipelines = append(c.Pipelines, p)
+				return err
+			})
+		},
+		"classifiers": func(v value) error {
+			c.Models = nil
+			return v.list(func(v value) error {
+				m, err := decodeModel(v)
+				c.Models = append(c.Models, m)
+				return err
+			})
+		},
+		"output": func(v value) error {
+			return v.object(fields{
+				"format": func(v value) error {
+					format, err := v.str()
+					if err == nil && format != "text" && format != "json" {
+						err = v.errorf("must be text or json, got %q", format)
+					}
+					c.Output = format
+					return err
+				},
+				"vocab_sizes": func(v value) error {
+					var sizes []string
+					err := v.list(func(v value) error {
+						size, err := v.integer()
+						if err == nil && size <= 0 {
+							err = v.errorf("must be positive, got %d", size)
+						}
+						sizes = append(sizes, strconv.Itoa(size))
+						return err
+					})
+					c.VocabSizes = strings.Join(sizes, ",")
+					return err
+				},
+				"baseline":  v.stringTo(&c.Baseline),
+				"bootstrap": v.countTo(&c.Bootstrap),
+				"runs":      v.stringTo(&c.Runs),
+			})
+		},
+	})
+}
+
+// preprocessors are the preprocessors a pipeline can name.
+var preprocessors = map[string]analysis.Preprocessor{
+	"stemmer":         parse.PreprocessStemmer{},
+	"no-punctuation":  parse.PreprocessRemovePunctuation{},
+	"no-common-words": parse.PreprocessRemoveCommonWords{},
+	"bigrams":         parse.PreprocessNGrams{N: 2},
+	"trigrams":        parse.PreprocessNGrams{N: 3},
+}
+
+func decodePipeline(v value) (analysis.Pipeline, error) {
+	var p analysis.Pipeline
+	err := v.object(fields{
+		"name": v.stringTo(&p.Name),
+		"preprocessors": func(v value) error {
+			return v.list(func(v value) error {
+				name, err := v.str()
+				if err != nil {
+					return err
+				}
+				pre, exists := preprocessors[name]
+				if !exists {
+					return v.errorf("unknown preprocessor %q, use %s", name, strings.Join(names(preprocessors), ", "))
+				}
+				p.Preprocessors = append(p.Preprocessors, pre)
+				return nil
+			})
+		},
+		"tfidf": func(v value) error {
+			p.TFIDF = &analysis.TFIDFOptions{}
+			return v.object(fields{
+				"sublinear": v.boolTo(&p.TFIDF.Sublinear),
+				"smooth":    v.boolTo(&p.TFIDF.Smooth),
+			})
+		},
+		"selection": func(v value) error {
+			p.Selection = &analysis.FeatureSelection{Ranking: analysis.ChiSquare}
+			var rank string
+			err := v.object(fields{
+				"min_df":    v.countTo(&p.Selection.MinDocumentFrequency),
+				"max_vocab": v.countTo(&p.Selection.MaxVocabulary),
+				"rank":      v.oneOf(&rank, analysis.LogLikelihoodRatio, analysis.ChiSquare, analysis.MutualInformation),
+			})
+			if rank != "" {
+				p.Selection.Ranking, _ = analysis.RankingType(rank)
+			}
+			return err
+		},
+	})
+	if err == nil && p.Name == "" {
+		err = v.errorf("pipeline needs a name")
+	}
+	return p, err
+}
+
+func decodeModel(v value) (analysis.Model, error) {
+	var name string
+	var lr analysis.LogisticRegression
+	var nb analysis.NaiveBayes
+	var prior string
+	// lrSetting and nbSetting mark a key that only applies to logistic
+	// regression or to naive bayes
+	var lrSetting, nbSetting string
+	only := func(setting *string, key string, decode func(value) error) func(value) error {
+		return func(v value) error {
+			*setting = key
+			return decode(v)
+		}
+	}
+	err := v.object(fields{
+		"model":         v.stringTo(&name),
+		"learning_rate": only(&lrSetting, "learning_rate", v.positiveTo(&lr.LearningRate)),
+		"lambda":        only(&lrSetting, "lambda", v.positiveTo(&lr.Lambda)),
+		"epochs": only(&lrSetting, "epochs", func(v value) error {
+			epochs, err := v.integer()
+			if err == nil && epochs <= 0 {
+				err = v.errorf("must be more than 0, got %d", epochs)
+			}
+			lr.Epochs = epochs
+			return err
+		}),
+		"seed": only(&lrSetting, "seed", func(v value) error {
+			seed, err := v.integer()
+			lr.Seed = int64(seed)
+			return err
+		}),
+		"alpha": only(&nbSetting, "alpha", v.positiveTo(&nb.Alpha)),
+		"prior": only(&nbSetting, "prior", v.oneOf(&prior, analysis.UniformPrior, analysis.ClassPrior)),
+	})
+	if err != nil {
+		return analysis.Model{}, err
+	}
+	if prior != "" {
+		nb.Prior, _ = analysis.PriorType(prior)
+	}
+	m, err := analysis.ModelNamed(name)
+	if err != nil {
+		return m, v.key("model").errorf("%s, use naive-bayes or logistic-regression", err)
+	}
+	switch m.New().(type) {
+	case *analysis.LogisticRegression:
+		if nbSetting != "" {
+			return m, v.key(nbSetting).errorf("only applies to naive-bayes")
+		}
+		// the L2 penalty shrinks the weights by learning_rate*lambda a step
+		if learningRate, lambda, _ := lr.Hyperparameters(); learningRate*lambda >= 1 {
+			return m, v.key("lambda").errorf("learning_rate times lambda must be less than 1, got %g", learningRate*lambda)
+		}
+		m.New = func() analysis.Classifier {
+			c := lr
+			return &c
+		}
+	case *analysis.NaiveBayes:
+		if lrSetting != "" {
+			return m, v.key(lrSetting).errorf("only applies to logistic-regression")
+		}
+		m.New = func() analysis.Classifier {
+			c := nb
+			return &c
+		}
+	}
+	return m, nil
+}
+
+func names(m map[string]analysis.Preprocessor) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// value is a decoded config value and the key path it was found at, so
+// errors can point at the offending key.
+type value struct {
+	path string
+	v    interface{}
+}
+
+// fields maps the keys an object may have to the function decoding each.
+type fields map[string]func(value) error
+
+func (v value) errorf(format string, args ...interface{}) error {
+	path := v.path
+	if path == "" {
+		path = "config"
+	}
+	return fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...))
+}
+
+func (v value) key(k string) value {
+	if v.path == "" {
+		return value{path: k}
+	}
+	return value{path: v.path + "." + k}
+}
+
+// object decodes every key of an object in order, failing on keys it does
+// not know.
+func (v value) object(f fields) error {
+	m, ok := v.v.(map[string]interface{})
+	if !ok {
+		return v.errorf("expected a table of keys, got %s", kind(v.v))
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := v.key(k)
+		child.v = m[k]
+		decode, known := f[k]
+		if !known {
+			return child.errorf("unknown key")
+		}
+		if err := decode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v value) list(fn func(value) error) error {
+	items, ok := v.v.([]interface{})
+	if !ok {
+		// TOML decodes arrays of tables into a typed slice
+		if tables, isTables := v.v.([]map[string]interface{}); isTables {
+			for _, t := range tables {
+				items = append(items, t)
+			}
+			ok = true
+		}
+	}
+	if !ok {
+		return v.errorf("expected a list, got %s", kind(v.v))
+	}
+	for i, item := range items {
+		if err := fn(value{path: fmt.Sprintf("%s[%d]", v.path, i), v: item}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v value) str() (string, error) {
+	s, ok := v.v.(string)
+	if !ok {
+		return "", v.errorf("expected a string, got %s", kind(v.v))
+	}
+	return s, nil
+}
+
+func (v value) number() (float64, error) {
+	switch n := v.v.(type) {
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	}
+	return 0, v.errorf("expected a number, got %s", kind(v.v))
+}
+
+func (v value) integer() (int, error) {
+	n, err := v.number()
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) {
+		return 0, v.errorf("expected a whole number, got %g", n)
+	}
+	return int(n), nil
+}
+
+func (v value) boolean() (bool, error) {
+	b, ok := v.v.(bool)
+	if !ok {
+		return false, v.errorf("expected true or false, got %s", kind(v.v))
+	}
+	return b, nil
+}
+
+// The ...To methods return a decoder that stores the value in dst.
+
+func (value) stringTo(dst *string) func(value) error {
+	return func(v value) (err error) {
+		*dst, err = v.str()
+		return err
+	}
+}
+
+func (value) intTo(dst *int) func(value) error {
+	return func(v value) (err error) {
+		*dst, err = v.integer()
+		return err
+	}
+}
+
+// countTo accepts a whole number that is not negative.
+func (value) countTo(dst *int) func(value) error {
+	return func(v value) (err error) {
+		*dst, err = v.integer()
+		if err == nil && *dst < 0 {
+			err = v.errorf("must not be negative, got %d", *dst)
+		}
+		return err
+	}
+}
+
+// positiveTo accepts a number more than 0.
+func (value) positiveTo(dst *float64) func(value) error {
+	return func(v value) (err error) {
+		*dst, err = v.number()
+		if err == nil && *dst <= 0 {
+			err = v.errorf("must be more than 0, got %g", *dst)
+		}
+		return err
+	}
+}
+
+func (value) boolTo(dst *bool) func(value) error {
+	return func(v value) (err error) {
+		*dst, err = v.boolean()
+		return err
+	}
+}
+
+// columnTo accepts a column by index or by name.
+func (value) columnTo(dst *string) func(value) error {
+	return func(v value) error {
+		if s, ok := v.v.(string); ok {
+			*dst = s
+			return nil
+		}
+		n, err := v.integer()
+		if err != nil {
+			return v.errorf("expected a column index or name, got %s", kind(v.v))
+		}
+		*dst = strconv.Itoa(n)
+		return nil
+	}
+}
+
+// oneOf accepts the name of one of the choices.
+func (value) oneOf(dst *string, choices ...fmt.Stringer) func(value) error {
+	return func(v value) error {
+		s, err := v.str()
+		if err != nil {
+			return err
+		}
+		var valid []string
+		for _, c := range choices {
+			if c.String() == s {
+				*dst = s
+				return nil
+			}
+			valid = append(valid, c.String())
+		}
+		return v.errorf("invalid value %q, use %s", s, strings.Join(valid, ", "))
+	}
+}
+
+func kind(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nothing"
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case bool:
+		return fmt.Sprintf("%t", v)
+	case int, int64, float64:
+		return fmt.Sprintf("number %v", v)
+	case []interface{}, []map[string]interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "a table"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing %s: %s", name, err)
+	}
+	return path
+}
+
+func TestReadConfigFile(t *testing.T) {
+	files := map[string]string{
+		"exp.yaml": "dataset:\n  file: sms.csv\n  format: csv\n  label_column: label\nsplit:\n  train_ratio: 0.8\n  seed: 7\n" +
+			"pipelines:\n  - name: Stemmed\n    preprocessors: [stemmer]\n    tfidf: {sublinear: true}\n" +
+			"classifiers:\n  - model: logistic-regression\n    epochs: 3\noutput:\n  format: json\n  vocab_sizes: [10, 20]\n",
+		"exp.json": `{"dataset": {"file": "sms.csv", "format": "csv", "label_column": "label"}, "split": {"train_ratio": 0.8, "seed": 7},
+			"pipelines": [{"name": "Stemmed", "preprocessors": ["stemmer"], "tfidf": {"sublinear": true}}],
+			"classifiers": [{"model": "logistic-regression", "epochs": 3}], "output": {"format": "json", "vocab_sizes": [10, 20]}}`,
+		"exp.toml": "[dataset]\nfile = \"sms.csv\"\nformat = \"csv\"\nlabel_column = \"label\"\n[split]\ntrain_ratio = 0.8\nseed = 7\n" +
+			"[output]\nformat = \"json\"\nvocab_sizes = [10, 20]\n" +
+			"[[pipelines]]\nname = \"Stemmed\"\npreprocessors = [\"stemmer\"]\n[pipelines.tfidf]\nsublinear = true\n" +
+			"[[classifiers]]\nmodel = \"logistic-regression\"\nepochs = 3\n",
+	}
+	for name, content := range files {
+		cfg := defaultConfig()
+		if err := readConfigFile(writeConfig(t, name, content), &cfg); err != nil {
+			t.Errorf("reading %s: %s", name, err)
+			continue
+		}
+		if cfg.File != "sms.csv" || cfg.Format != "csv" || cfg.LabelColumn != "label" || cfg.TrainRatio != 0.8 || cfg.Seed != 7 ||
+			cfg.Output != "json" || cfg.VocabSizes != "10,20" || cfg.Delimiter != "\t" {
+			t.Errorf("reading %s: got %+v", name, cfg)
+		}
+		if len(cfg.Pipelines) != 1 || len(cfg.Pipelines[0].Preprocessors) != 1 || cfg.Pipelines[0].TFIDF == nil || !cfg.Pipelines[0].TFIDF.Sublinear {
+			t.Errorf("reading the pipelines of %s: got %+v", name, cfg.Pipelines)
+		}
+		if len(cfg.Models) != 1 || cfg.Models[0].Name != "Logistic Regression" {
+			t.Errorf("reading the classifiers of %s: got %+v", name, cfg.Models)
+		}
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	files := map[string]string{
+		"unknown.yaml":   "dataset:\n  delimeter: \",\"\n",
+		"type.json":      `{"pipelines": [{"name": "a"}, {"name": "b", "tfidf": {"smooth": "yes"}}]}`,
+		"model.toml":     "[[classifiers]]\nmodel = \"naive-bayes\"\nepochs = 3\n",
+		"ratio.toml":     "[split]\ntrain_ratio = 1.5\n",
+		"preprocess.yml": "pipelines:\n  - name: a\n    preprocessors: [stem]\n",
+		"alpha.yaml":     "classifiers:\n  - model: logistic-regression\n    alpha: 0.5\n",
+		"rate.yaml":      "classifiers:\n  - model: logistic-regression\n    learning_rate: 0\n",
+		"lambda.yaml":    "classifiers:\n  - model: logistic-regression\n    lambda: -1\n",
+		"penalty.yaml":   "classifiers:\n  - model: logistic-regression\n    learning_rate: 1\n    lambda: 1\n",
+		"epochs.yaml":    "classifiers:\n  - model: logistic-regression\n    epochs: 0\n",
+		"distance.yaml":  "dataset:\n  near_distance: -1\n",
+		"sample.yaml":    "split:\n  test_sample: -5\n",
+		"min_df.yaml":    "selection:\n  min_df: -2\n",
+		"vocab.json":     `{"pipelines": [{"name": "a", "selection": {"max_vocab": -10}}]}`,
+		"bootstrap.toml": "[output]\nbootstrap = -1\n",
+	}
+	keys := map[string]string{
+		"unknown.yaml":   "dataset.delimeter:",
+		"type.json":      "pipelines[1].tfidf.smooth:",
+		"model.toml":     "classifiers[0].epochs:",
+		"ratio.toml":     "split.train_ratio:",
+		"preprocess.yml": "pipelines[0].preprocessors[0]:",
+		"alpha.yaml":     "classifiers[0].alpha:",
+		"rate.yaml":      "classifiers[0].learning_rate: must be more than 0",
+		"lambda.yaml":    "classifiers[0].lambda: must be more than 0",
+		"penalty.yaml":   "classifiers[0].lambda: learning_rate times lambda must be less than 1",
+		"epochs.yaml":    "classifiers[0].epochs: must be more than 0",
+		"distance.yaml":  "dataset.near_distance: must not be negative",
+		"sample.yaml":    "split.test_sample: must not be negative",
+		"min_df.yaml":    "selection.min_df: must not be negative",
+		"vocab.json":     "pipelines[0].selection.max_vocab: must not be negative",
+		"bootstrap.toml": "output.bootstrap: must not be negative",
+	}
+	for name, content := range files {
+		cfg := defaultConfig()
+		err := readConfigFile(writeConfig(t, name, content), &cfg)
+		if err == nil || !strings.Contains(err.Error(), keys[name]) {
+			t.Errorf("reading %s: expected an error at %s, got %v", name, keys[name], err)
+		}
+	}
+}
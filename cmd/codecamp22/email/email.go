@@ -0,0 +1,196 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Message is what the classifier uses of an RFC 5322 email.
+type Message struct {
+	// FromDomain is the domain of the sender address
+	FromDomain string
+	Subject    string
+	// Body is the plain text of the message, with HTML parts stripped to text
+	Body string
+}
+
+// Parse reads an RFC 5322 message, decoding encoded headers, MIME parts and
+// quoted-printable or base64 bodies.
+func Parse(r io.Reader) (Message, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return Message{}, fmt.Errorf("reading message: %w", err)
+	}
+	var m Message
+	decoder := mime.WordDecoder{CharsetReader: charsetReader}
+	if subject, err := decoder.DecodeHeader(msg.Header.Get("Subject")); err == nil {
+		m.Subject = subject
+	} else {
+		m.Subject = msg.Header.Get("Subject")
+	}
+	if from, err := msg.Header.AddressList("From"); err == nil && len(from) > 0 {
+		if at := strings.LastIndex(from[0].Address, "@"); at >= 0 {
+			m.FromDomain = strings.ToLower(from[0].Address[at+1:])
+		}
+	}
+
+	plain, htmlText, err := readPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return Message{}, err
+	}
+	m.Body = plain
+	if strings.TrimSpace(m.Body) == "" {
+		m.Body = htmlText
+	}
+	return m, nil
+}
+
+// Text renders the message as a single line of words for the classifier. The
+// header tokens come first: "from:" and the sender domain, then every subject
+// word prefixed with "subj:", followed by the words of the body.
+func (m Message) Text() string {
+	var words []string
+	if m.FromDomain != "" {
+		words = append(words, "from:"+m.FromDomain)
+	}
+	for _, word := range strings.Fields(m.Subject) {
+		words = append(words, "subj:"+word)
+	}
+	words = append(words, strings.Fields(m.Body)...)
+	return strings.Join(words, " ")
+}
+
+// readPart returns the plain text and the stripped HTML text of a MIME part,
+// descending into multipart parts.
+func readPart(contentType, transferEncoding string, body io.Reader) (string, string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// messages without a (valid) content type are plain text
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var plain, htmlText []string
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", "", fmt.Errorf("reading %s part: %w", mediaType, err)
+			}
+			p, h, err := readPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", "", err
+			}
+			plain, htmlText = append(plain, p), append(htmlText, h)
+		}
+		return strings.Join(plain, " "), strings.Join(htmlText, " "), nil
+	}
+
+	if !strings.HasPrefix(mediaType, "text/") {
+		// attachments carry no words
+		return "", "", nil
+	}
+	decoded, err := io.ReadAll(decodeTransfer(transferEncoding, body))
+	if err != nil {
+		return "", "", fmt.Errorf("decoding %s body: %w", mediaType, err)
+	}
+	text := decodeCharset(params["charset"], decoded)
+	if mediaType == "text/html" {
+		return "", StripHTML(text), nil
+	}
+	return text, "", nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	default:
+		return body
+	}
+}
+
+// newlineStripper drops the line breaks base64 bodies are wrapped with.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	for {
+		count, err := n.r.Read(p)
+		kept := 0
+		for _, b := range p[:count] {
+			if b != '\r' && b != '\n' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// decodeCharset converts Latin-1 and Windows-1252 text to UTF-8. Other
+// charsets are assumed to be UTF-8 compatible.
+func decodeCharset(charset string, text []byte) string {
+	charset = strings.ToLower(charset)
+	switch charset {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(text))
+		for i, b := range text {
+			runes[i] = rune(b)
+			// Windows-1252 has printable characters where Latin-1 has
+			// control codes
+			if b >= 0x80 && b <= 0x9f && (charset == "windows-1252" || charset == "cp1252") && windows1252[b-0x80] != 0 {
+				runes[i] = windows1252[b-0x80]
+			}
+		}
+		return string(runes)
+	default:
+		return string(text)
+	}
+}
+
+// windows1252 maps the bytes 0x80 to 0x9f of Windows-1252 to Unicode. The
+// five bytes it leaves undefined are zero and decode like Latin-1.
+var windows1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	text, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader([]byte(decodeCharset(charset, text))), nil
+}
+
+var (
+	invisibleElements = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)\s*>`)
+	htmlComments      = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTags          = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// StripHTML returns the visible text of an HTML document.
+func StripHTML(document string) string {
+	text := invisibleElements.ReplaceAllString(document, " ")
+	text = htmlComments.ReplaceAllString(text, " ")
+	text = htmlTags.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
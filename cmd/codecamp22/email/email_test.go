@@ -0,0 +1,72 @@
+package email_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/email"
+)
+
+const multipartMessage = "From: Prize Team <winner@Lottery.example>\r\n" +
+	"Subject: =?utf-8?q?You_have_WON?=\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Claim your =E2=82=AC1000 cash=\r\n" +
+	" prize now\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"PGI+Q2xhaW08L2I+IHlvdXIgcHJpemU=\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf\r\n" +
+	"\r\n" +
+	"%PDF-1.4\r\n" +
+	"--outer--\r\n"
+
+func TestParseMultipart(t *testing.T) {
+	msg, err := email.Parse(strings.NewReader(multipartMessage))
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	expected := "from:lottery.example subj:You subj:have subj:WON Claim your €1000 cash prize now"
+	if msg.Text() != expected {
+		t.Errorf("text: expected %q, got %q", expected, msg.Text())
+	}
+}
+
+func TestParseHTMLOnly(t *testing.T) {
+	raw := "Subject: hi\r\nContent-Type: text/html\r\n\r\n" +
+		"<html><head><title>x</title><style>p {}</style></head><body><p>Free&nbsp;entry</p><!-- hidden --></body></html>"
+	msg, err := email.Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	if msg.Body != "Free entry" {
+		t.Errorf("body: expected the visible html text, got %q", msg.Body)
+	}
+}
+
+func TestParseWindows1252(t *testing.T) {
+	raw := "Subject: =?windows-1252?q?=93Free=94_offer?=\r\nContent-Type: text/plain; charset=windows-1252\r\n\r\n" +
+		"Win \x80100 \x96 it\x92s caf\xe9 time\x85"
+	msg, err := email.Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("parsing: %s", err)
+	}
+	if msg.Subject != "“Free” offer" {
+		t.Errorf("subject: expected curly quotes, got %q", msg.Subject)
+	}
+	if msg.Body != "Win €100 – it’s café time…" {
+		t.Errorf("body: expected the Windows-1252 characters, got %q", msg.Body)
+	}
+}
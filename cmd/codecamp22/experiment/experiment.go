@@ -17,6 +17,20 @@ func (c Class) String() string {
 	}
 }
 
+// MarshalText encodes the class by its name, so it reads as "ham" or "spam" in JSON.
+func (c Class) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Class) UnmarshalText(text []byte) error {
+	class, err := ClassType(string(text))
+	if err != nil {
+		return err
+	}
+	*c = class
+	return nil
+}
+
 func ClassType(str string) (Class, error) {
 	switch str {
 	case HamClass.String():
@@ -51,4 +65,19 @@ type TestSet struct {
 type TestCase struct {
 	Class Class
 	Text  string
+	// Line is the line of the original dataset the test case was read from
+	Line int
+	// Source is the file the test case was read from, for datasets of several
+	// files such as mbox directories
+	Source string
+}
+
+// Copy returns a deep copy of the experiment, so preprocessors can change the
+// messages of the copy without touching the original.
+func (ex Experiment) Copy() Experiment {
+	c := ex
+	c.Classes.Ham = append([]string(nil), ex.Classes.Ham...)
+	c.Classes.Spam = append([]string(nil), ex.Classes.Spam...)
+	c.Test.Cases = append([]TestCase(nil), ex.Test.Cases...)
+	return c
 }
@@ -0,0 +1,64 @@
+package feedback
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
+)
+
+// Store is a local file of corrected labeled messages, in the same
+// "label<delimiter>text" format as the training data parse reads.
+type Store struct {
+	Path string
+	// Delimiter separates the label from the text, a tab when empty
+	Delimiter string
+}
+
+func (s Store) delimiter() string {
+	if s.Delimiter == "" {
+		return "\t"
+	}
+	return s.Delimiter
+}
+
+// Append adds a labeled message to the end of the store, creating the file if
+// it does not exist. Line breaks and delimiters in the text are replaced by
+// spaces so the message stays on a single line. It returns the text as it was
+// stored, which is what Load reads back and what should be learned.
+func (s Store) Append(class bayes.Class, text string) (string, error) {
+	text = strings.NewReplacer("\r", " ", "\n", " ", s.delimiter(), " ").Replace(text)
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("appending feedback: empty text")
+	}
+	file, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("appending feedback to %s: %w", s.Path, err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "%s%s%s\n", class, s.delimiter(), text); err != nil {
+		return "", fmt.Errorf("appending feedback to %s: %w", s.Path, err)
+	}
+	return text, nil
+}
+
+// Load returns every message in the store. A store that does not exist yet
+// is empty.
+func (s Store) Load() ([]bayes.Example, error) {
+	var examples []bayes.Example
+	_, err := parse.ScanFile(s.Path, parse.Options{Delimiter: s.delimiter()}, func(r parse.Record) error {
+		examples = append(examples, r.Example())
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading feedback: %w", err)
+	}
+	return examples, nil
+}
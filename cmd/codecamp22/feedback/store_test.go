@@ -0,0 +1,65 @@
+package feedback_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/feedback"
+)
+
+func TestStore(t *testing.T) {
+	store := feedback.Store{Path: filepath.Join(t.TempDir(), "feedback.data"), Delimiter: "\t"}
+	if examples, err := store.Load(); err != nil || len(examples) != 0 {
+		t.Fatalf("loading a store that does not exist: expected no examples, got %v, %v", examples, err)
+	}
+
+	text, err := store.Append(bayes.SpamClass, "free\tcash\r\nnow")
+	if err != nil {
+		t.Fatalf("appending: %s", err)
+	}
+	if text != "free cash  now" {
+		t.Errorf("appending a message with a delimiter and line breaks: expected them replaced by spaces, got %q", text)
+	}
+	if _, err := store.Append(bayes.HamClass, "see you at lunch"); err != nil {
+		t.Fatalf("appending: %s", err)
+	}
+	if _, err := store.Append(bayes.HamClass, " \n "); err == nil {
+		t.Errorf("appending an empty message: expected an error")
+	}
+
+	examples, err := store.Load()
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	expected := []bayes.Example{{Class: bayes.SpamClass, Text: text}, {Class: bayes.HamClass, Text: "see you at lunch"}}
+	if len(examples) != len(expected) {
+		t.Fatalf("loading: expected %d examples, got %v", len(expected), examples)
+	}
+	for i, e := range expected {
+		if examples[i] != e {
+			t.Errorf("loading example %d: expected %+v, got %+v", i, e, examples[i])
+		}
+	}
+	content, _ := os.ReadFile(store.Path)
+	if string(content) != "spam\tfree cash  now\nham\tsee you at lunch\n" {
+		t.Errorf("storing: expected one tab delimited line per message, got %q", content)
+	}
+}
+
+func TestStoreDefaultDelimiter(t *testing.T) {
+	store := feedback.Store{Path: filepath.Join(t.TempDir(), "feedback.data")}
+	text, err := store.Append(bayes.SpamClass, "free\tcash")
+	if err != nil || text != "free cash" {
+		t.Fatalf("appending without a delimiter: expected a tab replaced by a space, got %q, %v", text, err)
+	}
+	content, _ := os.ReadFile(store.Path)
+	if string(content) != "spam\tfree cash\n" {
+		t.Errorf("storing without a delimiter: expected a tab delimited line, got %q", content)
+	}
+	examples, err := store.Load()
+	if err != nil || len(examples) != 1 || examples[0] != (bayes.Example{Class: bayes.SpamClass, Text: "free cash"}) {
+		t.Errorf("loading without a delimiter: expected the message back, got %v, %v", examples, err)
+	}
+}
@@ -1,72 +1,97 @@
 package main
 
 import (
-	"bufio"
-	"flag"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
-	"sort"
-
 	"io"
-	"math/rand"
+	"math"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
 	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
 	"github.com/fatih/color"
 )
 
-// Whether to use a single textmessage and not testData
-var useTextMessageAsTest bool = true
-var numberOfCommonWordsForClass int = 5
-var textMessageSpam string = "free 2 txt"
-var textMessageHam string = "u have me and im in love with u 2"
-
-func main() {
-
-	run()
+// command is a subcommand of codecamp22, run with the arguments after its name.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
 }
 
-func run() {
-
-	var flagFilename string
-	flag.StringVar(&flagFilename, "file", "trainingData.data", "filename")
-	var flagDelimiter string
-	flag.StringVar(&flagDelimiter, "delimiter", "\t", "delimiter between class and words in data (default is tab)")
-	flag.Parse()
+var commands = []command{
+	{"train", "list the most discriminative words of every pipeline and save a trained model", runTrain},
+	{"eval", "evaluate every model on a held out split of the data", runEval},
+	{"classify", "classify a text message with every model", runClassify},
+	{"misclassified", "list the test messages the models got wrong", runMisclassified},
+	{"learning-curve", "evaluate every model trained on growing shares of the data", runLearningCurve},
+	{"search", "find the best Naive Bayes settings by cross validation", runSearch},
+	{"bench", "measure training and classification speed and memory", runBench},
+	{"runs", "list recorded eval runs or compare two of them", runRuns},
+	{"stats", "describe the data", runStats},
+	{"noise", "list messages that may be mislabeled", runNoise},
+	{"feedback", "correct the label of a message and learn it", runFeedback},
+	{"serve", "serve classification and feedback over HTTP", runServe},
+}
 
-	//Returns an experiment instance with seperated lines for SPAM and HAM respectivaley
-	//Also returns a testSet of test cases. one test case is a label and messagetext
-	exp, err := parse.FromFile(flagFilename, flagDelimiter, useTextMessageAsTest)
-	//Set textMEssageData
-	exp.TextMessage = textMessageHam
+func main() {
+	os.Exit(dispatch(commands, os.Args[1:], os.Stderr))
+}
 
-	if err != nil {
-		fmt.Println("cannot parse file:", err)
-		os.Exit(1)
+// dispatch runs the command named by the first argument with the rest of the
+// arguments and returns the exit status: 0 after the command, 2 when no
+// known command is named.
+func dispatch(commands []command, args []string, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(commands, stderr)
+		return 2
 	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			c.run(args[1:])
+			return 0
+		}
+	}
+	if args[0] != "help" && args[0] != "-h" && args[0] != "-help" {
+		fmt.Fprintf(stderr, "unknown command: %s\n\n", args[0])
+	}
+	usage(commands, stderr)
+	return 2
+}
 
-	analyses := analysis.Run(exp, useTextMessageAsTest)
-	if useTextMessageAsTest {
-		analyzeTextMessageClassification(analyses, exp.TextMessage)
-	} else {
-		analyzeTestDataClassification(analyses)
+func usage(commands []command, w io.Writer) {
+	fmt.Fprintln(w, "Usage: codecamp22 <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t%-16s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintln(w, "\nRun codecamp22 <command> -h for the flags of a command.")
+}
 
-	fmt.Println("\nDone.")
+// printReport summarizes what was read from the data, listing every
+// problematic line when diagnostics is set.
+func printReport(report parse.Report, diagnostics bool) {
+	c := color.New(color.FgYellow)
+	c.Printf("Data: %s\n", report)
+	if diagnostics {
+		for _, d := range report.Diagnostics {
+			fmt.Println("\t" + d.Error())
+		}
+		if report.Unlisted > 0 {
+			fmt.Printf("\tand %d more\n", report.Unlisted)
+		}
+	}
+	fmt.Println()
 }
 
 func analyzeTestDataClassification(analyses analysis.Analyses) {
 	for _, a := range analyses {
-		c := color.New(color.FgCyan).Add(color.Underline)
-		c.Printf("Analysis: %s\n", a.Name)
-		fmt.Println("Vocabulary has", len(a.TrainingSet.Vocabulary), "words")
-		fmt.Println("\nTraining Set:")
-		fmt.Printf("\t%d of %d messages were spam (%.2f%%)\n\n",
-			a.TrainingSet.Spam.MessageTotal,
-			a.TrainingSet.MessageTotal,
-			a.TrainingSet.Spam.PofC*100)
+		printTrainingSet(a)
 		fmt.Println("Test Set:")
 		fmt.Println("\tCorrect Ham:", a.TestSet.CorrectHam)
 		fmt.Println("\tCorrect Spam:", a.TestSet.CorrectSpam)
@@ -75,205 +100,552 @@ func analyzeTestDataClassification(analyses analysis.Analyses) {
 		fmt.Printf("\tPercentage Correct Ham: %.2f%%\n", a.TestSet.PercentageCorrectHam*100)
 		fmt.Printf("\tPercentage Correct Spam: %.2f%%\n", a.TestSet.PercentageCorrectSpam*100)
 		bold := color.New(color.FgGreen, color.Bold)
-		bold.Printf("\tOverall Accuracy: %.2f%%\n",
-			100*(float64(a.TestSet.CorrectSpam)+float64(a.TestSet.CorrectHam))/
-				float64(a.TestSet.MessageTotal))
+		bold.Printf("\tOverall Accuracy: %.2f%%\n", 100*a.TestSet.Accuracy())
 		fmt.Println()
 
 	}
 }
 
-func analyzeTextMessageClassification(analyses analysis.Analyses, textMessage string) {
-	for _, a := range analyses {
-		c := color.New(color.FgCyan).Add(color.Underline)
-		c.Printf("Analysis: %s\n", a.Name)
-		fmt.Println("Vocabulary has", len(a.TrainingSet.Vocabulary), "words")
-		fmt.Println("\nTraining Set:")
-		fmt.Printf("\t%d of %d messages were spam (%.2f%%)\n\n",
-			a.TrainingSet.Spam.MessageTotal,
-			a.TrainingSet.MessageTotal,
-			a.TrainingSet.Spam.PofC*100)
+// analyzeSignificance tests whether each pipeline differs from the baseline
+// pipeline by more than chance, with McNemar's test and a paired bootstrap
+// interval of the difference in accuracy.
+func analyzeSignificance(analyses analysis.Analyses, baseline string, samples int, seed int64) {
+	comparisons, err := analysis.ComparePipelines(analyses, baseline, samples, seed)
+	if err != nil {
+		fmt.Println("cannot test significance:", err)
+		return
+	}
+	if len(comparisons) == 0 {
+		return
+	}
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Printf("Significance against %s (McNemar's test, 95%% bootstrap interval)\n", comparisons[0].Baseline)
+	fmt.Printf("\t%-60s %9s %21s %6s %6s %8s\n", "Analysis", "Change", "Interval", "Base", "This", "p")
+	for _, cmp := range comparisons {
+		line := fmt.Sprintf("\t%-60s %+8.2f%% [%+7.2f%%, %+7.2f%%] %6d %6d %8.4f",
+			cmp.Pipeline+" ["+cmp.Model+"]", cmp.Accuracy.Difference*100, cmp.Accuracy.Lower*100, cmp.Accuracy.Upper*100,
+			cmp.McNemar.FirstOnly, cmp.McNemar.SecondOnly, cmp.McNemar.PValue)
+		if cmp.Significant() {
+			color.New(color.Bold).Println(line + "  significant")
+		} else {
+			fmt.Println(line)
+		}
+	}
+	fmt.Println("\tBase and This count the test cases only the baseline or only this pipeline classified correctly.")
+	fmt.Println()
+}
 
-		bold := color.New(color.FgGreen, color.Bold)
-		boldBlue := color.New(color.FgHiBlue, color.Bold)
-		bold.Println("The five most common HAM words")
-		mostCommonHamWords := getMostCommonWords(numberOfCommonWordsForClass, a.TrainingSet.Ham.WordFrequency)
-		for i := len(mostCommonHamWords) - 1; i >= 0; i-- {
-			boldBlue.Printf("Word")
-			fmt.Println("\t\t", mostCommonHamWords[i].Word)
-			boldBlue.Printf("Word frequancy")
-			fmt.Println("\t", mostCommonHamWords[i].Frequency)
+// printMisclassified lists at most limit mistakes, all of them when limit is 0.
+func printMisclassified(mistakes []analysis.Misclassification, limit int) {
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Printf("%d misclassified test messages\n", len(mistakes))
+	if limit > 0 && len(mistakes) > limit {
+		fmt.Printf("Listing the %d the models were most sure of, use -limit 0 to list all\n", limit)
+		mistakes = mistakes[:limit]
+	}
+	fmt.Println()
+	bold := color.New(color.Bold)
+	for _, m := range mistakes {
+		kind := "false negative, spam classified as ham"
+		if m.Mistake == analysis.FalsePositive {
+			kind = "false positive, ham classified as spam"
 		}
-		fmt.Println("")
-		bold.Println("The five most common SPAM words")
-		mostCommonSpamWords := getMostCommonWords(numberOfCommonWordsForClass, a.TrainingSet.Spam.WordFrequency)
-		for i := len(mostCommonHamWords) - 1; i >= 0; i-- {
-			boldBlue.Printf("Word")
-			fmt.Println("\t\t", mostCommonSpamWords[i].Word)
-			boldBlue.Printf("Word frequancy")
-			fmt.Println("\t", mostCommonSpamWords[i].Frequency)
+		bold.Printf("%s: %s\n", m.Location(), kind)
+		fmt.Printf("\t%s [%s], confidence %.4f (spam probability %.4f)\n", m.Analysis, m.Model, m.Confidence(), m.SpamProbability)
+		fmt.Printf("\tText: %s\n", m.Original)
+		if len(m.Words) > 0 {
+			fmt.Printf("\tWords: %s\n", contributionList(m.Words))
 		}
-
-		fmt.Println("")
-		fmt.Println("")
-
-		boldRed := color.New(color.FgRed, color.Bold)
-		boldRed.Printf("Text Message: ")
-		fmt.Println(textMessage)
-		boldRed.Printf("Classifies as: ")
-		fmt.Println(a.FoundClass.String())
 		fmt.Println()
+	}
+}
 
+// contributionList formats words with their log odds, e.g. "free (+2.31)".
+func contributionList(words []analysis.WordContribution) string {
+	list := make([]string, len(words))
+	for i, w := range words {
+		list[i] = fmt.Sprintf("%s (%+.2f)", w.Word, w.LogOdds)
 	}
+	return strings.Join(list, ", ")
 }
 
-func getMostCommonWords(amountOfWords int, wordFrequencies map[string]int) []WordFrequencyPair {
+// writeMisclassifiedCSV writes the mistakes to a CSV file with a header row.
+func writeMisclassifiedCSV(path string, mistakes []analysis.Misclassification) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"analysis", "model", "source", "line", "label", "predicted", "mistake", "spam_probability", "confidence", "text", "words"})
+	for _, m := range mistakes {
+		w.Write([]string{
+			m.Analysis,
+			m.Model,
+			m.Source,
+			strconv.Itoa(m.Line),
+			m.Class.String(),
+			m.Predicted.String(),
+			m.Mistake.String(),
+			strconv.FormatFloat(m.SpamProbability, 'f', 6, 64),
+			strconv.FormatFloat(m.Confidence(), 'f', 6, 64),
+			m.Original,
+			contributionList(m.Words),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
-	sortedProbabilityList := make([]WordFrequencyPair, 0, amountOfWords)
-	for key := range wordFrequencies {
+// analyzeDeduplication removes the duplicates from the split experiment and
+// compares the accuracy of every analysis with and without them.
+func analyzeDeduplication(exp experiment.Experiment, before analysis.Analyses, pipelines []analysis.Pipeline, models []analysis.Model, dedup parse.Dedup, distance int) {
+	deduped, leakage := parse.Deduplicate(exp, dedup, distance)
+	after := analysis.Run(deduped, analysis.Options{Pipelines: pipelines, Models: models, Evaluate: true})
+
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Printf("Deduplication (%s)\n", dedup)
+	fmt.Printf("\t%s\n", leakage)
+	fmt.Printf("\t%d of %d test cases repeat a training message (%.2f%%)\n\n",
+		leakage.CrossSplit, len(exp.Test.Cases), percent(leakage.CrossSplit, len(exp.Test.Cases)))
+	fmt.Printf("\t%-60s %10s %10s %10s\n", "Analysis", "Before", "After", "Change")
+	for i, a := range before {
+		b, f := a.TestSet.Accuracy(), after[i].TestSet.Accuracy()
+		fmt.Printf("\t%-60s %9.2f%% %9.2f%% %+9.2f%%\n", a.Name+" ["+a.Model+"]", b*100, f*100, (f-b)*100)
+	}
+	fmt.Println()
+}
 
-		sortedProbabilityList = append(sortedProbabilityList,
-			WordFrequencyPair{Word: key, Frequency: wordFrequencies[key]})
+func analyzeVocabularySizes(exp experiment.Experiment, pipelines []analysis.Pipeline, models []analysis.Model, selection analysis.FeatureSelection, sizes []int) {
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Printf("Accuracy vs vocabulary size (min document frequency %d, ranked by %s)\n", selection.MinDocumentFrequency, selection.Ranking)
+	for _, p := range pipelines {
+		for _, m := range models {
+			fmt.Printf("%s [%s]\n", p.Name, m.Name)
+			fmt.Printf("\t%10s %10s %10s\n", "Max", "Words", "Accuracy")
+			for _, point := range analysis.VocabularyCurve(exp, p, m, selection, sizes) {
+				fmt.Printf("\t%10d %10d %9.2f%%\n", point.MaxVocabulary, point.VocabularySize, point.Accuracy*100)
+			}
+		}
 	}
+	fmt.Println()
+}
 
-	sort.SliceStable(sortedProbabilityList, func(i, j int) bool {
-		return sortedProbabilityList[i].Frequency < sortedProbabilityList[j].Frequency
-	})
-	return sortedProbabilityList[len(sortedProbabilityList)-amountOfWords:]
+// parseSizes parses a comma separated list of positive integers.
+func parseSizes(list string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		size, err := strconv.Atoi(field)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size: %s", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
 }
 
-type WordFrequencyPair struct {
-	Word      string
-	Frequency int
+// parseCounts parses a comma separated list of integers that are not negative.
+func parseCounts(list string) ([]int, error) {
+	var counts []int
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		count, err := strconv.Atoi(field)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid count: %s", field)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
 }
 
-func parseTrainingData(filename, delimiter string) ([]pair, error) {
-	//Try to open file
-	file, err := os.Open(filename)
-	//If error return
-	if err != nil {
-		return []pair{}, fmt.Errorf("reading %s: %w", filename, err)
+// parseFloats parses a comma separated list of positive numbers.
+func parseFloats(list string) ([]float64, error) {
+	var values []float64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid number: %s", field)
+		}
+		values = append(values, value)
 	}
-	defer file.Close()
-	return parseData(file, delimiter)
+	return values, nil
 }
 
-func parseData(reader io.Reader, delimiter string) ([]pair, error) {
-	//ratioToTrain := 0.75
+// parseFractions parses a comma separated list of shares above 0 and at most 1.
+func parseFractions(list string) ([]float64, error) {
+	var fractions []float64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		fraction, err := strconv.ParseFloat(field, 64)
+		if err != nil || fraction <= 0 || fraction > 1 {
+			return nil, fmt.Errorf("invalid fraction: %s", field)
+		}
+		fractions = append(fractions, fraction)
+	}
+	return fractions, nil
+}
 
-	var labeledPairs []pair
+// printSearchResults lists the top best results of a hyperparameter search.
+func printSearchResults(results []analysis.SearchResult, top int) {
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Println("Best combinations by cross validated accuracy")
+	if top > 0 && len(results) > top {
+		results = results[:top]
+	}
+	fmt.Printf("\t%4s %-40s %6s %6s %8s %9s %8s %9s %9s\n", "", "Pipeline", "Alpha", "Grams", "Min df", "Max vocab", "Prior", "Accuracy", "F1")
+	for i, r := range results {
+		line := fmt.Sprintf("\t%4d %-40s %6g %6d %8d %9d %8s %8.2f%% %8.2f%%",
+			i+1, r.Pipeline.Name, r.Alpha, r.NGrams, r.MinDF, r.MaxVocab, r.Prior, r.Accuracy*100, r.F1*100)
+		if i == 0 {
+			color.New(color.FgGreen, color.Bold).Println(line)
+		} else {
+			fmt.Println(line)
+		}
+	}
+	fmt.Println()
+}
 
-	// append works on nil slices.
+// benchResult are the measurements on a corpus scale times the size of the data.
+type benchResult struct {
+	Scale        int
+	Measurements []analysis.Measurement
+}
 
-	lines := getEveryLineToList(reader)
-	//read every line in file and add it to a list
+func printMeasurements(scale int, measurements []analysis.Measurement) {
+	c := color.New(color.FgCyan).Add(color.Underline)
+	if scale == 1 {
+		c.Println("Benchmark on the data")
+	} else {
+		c.Printf("Benchmark on a synthetic corpus %d times the size of the data\n", scale)
+	}
+	fmt.Printf("\t%-60s %9s %10s %11s %11s %9s %12s\n", "Analysis", "Messages", "Training", "Allocated", "Retained", "Tested", "Messages/s")
+	for _, m := range measurements {
+		fmt.Printf("\t%-60s %9d %10s %11s %11s %9d %12.0f\n", m.Pipeline+" ["+m.Model+"]", m.TrainingMessages,
+			m.Training.Round(time.Millisecond), formatBytes(m.Allocated), formatBytes(m.Retained), m.Classified, m.MessagesPerSecond())
+	}
+	fmt.Println()
+}
 
-	// randomize slice in-place so positioning of the line is non-dependant
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(lines), func(i, j int) {
-		//inner function to swap indexes
-		lines[i], lines[j] = lines[j], lines[i]
-	})
-	//the number of lines to train with
+// formatBytes formats a number of bytes in KiB or MiB.
+func formatBytes(n uint64) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+}
 
-	/*
-		if !useTextMessageAsTest {
-			numberToTrain := int(float64(len(lines)) * ratioToTrain)
+// writeBenchJSON writes the measurements of every scale.
+func writeBenchJSON(w io.Writer, results []benchResult) error {
+	type measurement struct {
+		Scale             int     `json:"scale"`
+		Analysis          string  `json:"analysis"`
+		Model             string  `json:"model"`
+		TrainingMessages  int     `json:"trainingMessages"`
+		TrainingSeconds   float64 `json:"trainingSeconds"`
+		AllocatedBytes    uint64  `json:"allocatedBytes"`
+		RetainedBytes     uint64  `json:"retainedBytes"`
+		TestMessages      int     `json:"testMessages"`
+		MessagesPerSecond float64 `json:"messagesPerSecond"`
+	}
+	var list []measurement
+	for _, r := range results {
+		for _, m := range r.Measurements {
+			list = append(list, measurement{
+				Scale:             r.Scale,
+				Analysis:          m.Pipeline,
+				Model:             m.Model,
+				TrainingMessages:  m.TrainingMessages,
+				TrainingSeconds:   m.Training.Seconds(),
+				AllocatedBytes:    m.Allocated,
+				RetainedBytes:     m.Retained,
+				TestMessages:      m.Classified,
+				MessagesPerSecond: m.MessagesPerSecond(),
+			})
 		}
-	*/
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
 
-	for i, line := range lines {
-		parts := strings.Split(line, delimiter)
-		// eliminate lines without a class
-		if len(parts) < 2 {
-			fmt.Println("skipped line because it had not class:", parts[0])
-			continue
-		}
-		thisClass, err := getClassType(parts[0])
+// learningCurve is the learning curve of a model on a pipeline.
+type learningCurve struct {
+	Pipeline string
+	Model    string
+	Points   []analysis.LearningPoint
+}
 
-		if err != nil {
-			return labeledPairs, fmt.Errorf("checking class type on shuffled row %d: %w", i, err)
+// printLearningCurve lists the points of the curve and draws the train and
+// test accuracy against the share of the training split.
+func printLearningCurve(curve learningCurve) {
+	c := color.New(color.FgCyan).Add(color.Underline)
+	c.Printf("Learning curve of %s [%s]\n", curve.Pipeline, curve.Model)
+	fmt.Printf("\t%10s %10s %10s %10s\n", "Fraction", "Messages", "Train", "Test")
+	for _, p := range curve.Points {
+		fmt.Printf("\t%9.0f%% %10d %9.2f%% %9.2f%%\n", p.Fraction*100, p.TrainingMessages, p.TrainAccuracy*100, p.TestAccuracy*100)
+	}
+	fmt.Println()
+	for _, line := range learningChart(curve.Points, 12) {
+		fmt.Println("\t" + line)
+	}
+	fmt.Println()
+}
+
+// learningChart draws the accuracies of the points in height rows, a column
+// of five characters for every point: o is the train accuracy, * the test
+// accuracy and # both.
+func learningChart(points []analysis.LearningPoint, height int) []string {
+	if len(points) == 0 || height < 2 {
+		return nil
+	}
+	low, high := 1.0, 0.0
+	for _, p := range points {
+		if math.IsNaN(p.TrainAccuracy) || math.IsNaN(p.TestAccuracy) {
+			return nil
 		}
-		if len(parts[1]) == 0 {
-			return labeledPairs, fmt.Errorf("empty text on shuffled row %d", i)
+		low = math.Min(low, math.Min(p.TrainAccuracy, p.TestAccuracy))
+		high = math.Max(high, math.Max(p.TrainAccuracy, p.TestAccuracy))
+	}
+	//Round the axis to whole percentages, leaving room when all are equal
+	low, high = math.Floor(low*100)/100, math.Ceil(high*100)/100
+	if high-low < .01 {
+		low = high - .01
+	}
+	row := func(accuracy float64) int {
+		return int(math.Round((high - accuracy) / (high - low) * float64(height-1)))
+	}
+	grid := make([][]byte, height)
+	for r := range grid {
+		grid[r] = []byte(strings.Repeat(" ", 5*len(points)))
+	}
+	for i, p := range points {
+		column := 5*i + 2
+		grid[row(p.TrainAccuracy)][column] = 'o'
+		if r := row(p.TestAccuracy); grid[r][column] == 'o' {
+			grid[r][column] = '#'
+		} else {
+			grid[r][column] = '*'
 		}
-		if thisClass == SpamClass {
-			labeledPairs = append(labeledPairs, pair{class: SpamClass.toString(), lineString: parts[1]})
+	}
+
+	var lines []string
+	for r, cells := range grid {
+		label := ""
+		if r == 0 || r == height-1 || r == (height-1)/2 {
+			label = fmt.Sprintf("%6.2f%%", (high-(high-low)*float64(r)/float64(height-1))*100)
 		}
-		if thisClass == HamClass {
-			labeledPairs = append(labeledPairs, pair{class: HamClass.toString(), lineString: parts[1]})
+		lines = append(lines, fmt.Sprintf("%8s |%s", label, strings.TrimRight(string(cells), " ")))
+	}
+	lines = append(lines, fmt.Sprintf("%8s +%s", "", strings.Repeat("-", 5*len(points))))
+	var axis strings.Builder
+	for _, p := range points {
+		axis.WriteString(fmt.Sprintf("%5s", fmt.Sprintf("%.0f%%", p.Fraction*100)))
+	}
+	lines = append(lines, fmt.Sprintf("%8s  %s", "", axis.String()))
+	lines = append(lines, fmt.Sprintf("%8s  o train  * test  # both", ""))
+	return lines
+}
+
+// writeLearningCurvesCSV writes every point of the curves to a CSV file with a
+// header row.
+func writeLearningCurvesCSV(path string, curves []learningCurve) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	w.Write([]string{"pipeline", "model", "fraction", "training_messages", "train_accuracy", "test_accuracy"})
+	for _, curve := range curves {
+		for _, p := range curve.Points {
+			w.Write([]string{
+				curve.Pipeline,
+				curve.Model,
+				strconv.FormatFloat(p.Fraction, 'f', -1, 64),
+				strconv.Itoa(p.TrainingMessages),
+				strconv.FormatFloat(p.TrainAccuracy, 'f', 6, 64),
+				strconv.FormatFloat(p.TestAccuracy, 'f', 6, 64),
+			})
 		}
-		/*
-			if !useTextMessageAsTest {
-				if i < numberToTrain {
-					// training sets
-					if thisClass == SpamClass {
-						ex.Classes.Spam = append(ex.Classes.Spam, parts[1])
-					}
-					if thisClass == HamClass {
-						ex.Classes.Ham = append(ex.Classes.Ham, parts[1])
-					}
-				} else {
-					// test sets
-					testCase := experiment.TestCase{
-						Class: thisClass,
-						Text:  parts[1],
-					}
-					ex.Test.Cases = append(ex.Test.Cases, testCase)
-				}
-			}
-		*/
 	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
-	return labeledPairs, nil
+// printTrainingSet prints the name of the analysis and what it was trained on,
+// leaving out the model when there is none.
+func printTrainingSet(a analysis.Analysis) {
+	c := color.New(color.FgCyan).Add(color.Underline)
+	if a.Model == "" {
+		c.Printf("Analysis: %s\n", a.Name)
+	} else {
+		c.Printf("Analysis: %s [%s]\n", a.Name, a.Model)
+	}
+	fmt.Println("Vocabulary has", len(a.TrainingSet.Vocabulary), "words")
+	fmt.Println("\nTraining Set:")
+	fmt.Printf("\t%d of %d messages were spam (%.2f%%)\n\n",
+		a.TrainingSet.Spam.MessageTotal,
+		a.TrainingSet.MessageTotal,
+		a.TrainingSet.Spam.PofC*100)
 }
 
-func getEveryLineToList(reader io.Reader) []string {
-	scanner := bufio.NewScanner(reader)
+func analyzeTextMessageClassification(analyses analysis.Analyses, textMessage string, explain bool) {
+	for _, a := range analyses {
+		printTrainingSet(a)
 
-	var lines []string
-	for scanner.Scan() {
-		line := scanner.Text()
-		// skip empty lines
-		if line == "" {
-			continue
+		boldRed := color.New(color.FgRed, color.Bold)
+		boldRed.Printf("Text Message: ")
+		fmt.Println(textMessage)
+		boldRed.Printf("Classifies as: ")
+		fmt.Println(a.FoundClass.String())
+		if explainer, ok := a.Classifier.(analysis.Explainer); ok && explain {
+			printExplanation(explainer.Explain(a.TextMessage))
 		}
-		lines = append(lines, line)
+		fmt.Println()
+
 	}
-	return lines
 }
 
-func getClassType(str string) (Class, error) {
-	switch str {
-	case HamClass.toString():
-		return HamClass, nil
-	case SpamClass.toString():
-		return SpamClass, nil
-	default:
-		return HamClass, fmt.Errorf("invalid class: %s", str)
+func printExplanation(e analysis.Explanation) {
+	boldRed := color.New(color.FgRed, color.Bold)
+	boldRed.Printf("Spam probability: ")
+	fmt.Printf("%.4f (log odds %.3f)\n", e.SpamProbability, e.LogOdds)
+	fmt.Printf("\t%-20s %-8s %13s %13s %10s\n", "Word", "Weight", "log P(w|ham)", "log P(w|spam)", "Log odds")
+	for _, w := range e.Words {
+		if !w.InVocabulary {
+			fmt.Printf("\t%-20s %-8.2f %13s %13s %10s\n", w.Word, w.Weight, "-", "-", "not in vocabulary")
+			continue
+		}
+		fmt.Printf("\t%-20s %-8.2f %13.3f %13.3f %+10.3f\n", w.Word, w.Weight, w.LogPHam, w.LogPSpam, w.LogOdds)
 	}
 }
 
-// Enum for the two classes
-const (
-	HamClass Class = iota
-	SpamClass
-)
+// writeEvaluationJSON writes the test results of every analysis, with the
+// comparison to the baseline pipeline for the others.
+func writeEvaluationJSON(w io.Writer, analyses analysis.Analyses, comparisons []analysis.Comparison) error {
+	type significance struct {
+		Baseline    string  `json:"baseline"`
+		Change      float64 `json:"change"`
+		Lower       float64 `json:"lower"`
+		Upper       float64 `json:"upper"`
+		BaseOnly    int     `json:"baseOnly"`
+		ThisOnly    int     `json:"thisOnly"`
+		PValue      float64 `json:"p"`
+		Significant bool    `json:"significant"`
+	}
+	type result struct {
+		Analysis              string        `json:"analysis"`
+		Model                 string        `json:"model"`
+		Vocabulary            int           `json:"vocabulary"`
+		TestMessages          int           `json:"testMessages"`
+		CorrectHam            int           `json:"correctHam"`
+		CorrectSpam           int           `json:"correctSpam"`
+		IncorrectHam          int           `json:"incorrectHam"`
+		IncorrectSpam         int           `json:"incorrectSpam"`
+		PercentageCorrectHam  float64       `json:"percentageCorrectHam"`
+		PercentageCorrectSpam float64       `json:"percentageCorrectSpam"`
+		Accuracy              float64       `json:"accuracy"`
+		Significance          *significance `json:"significance,omitempty"`
+	}
+	var results []result
+	for _, a := range analyses {
+		t := a.TestSet
+		r := result{
+			Analysis:              a.Name,
+			Model:                 a.Model,
+			Vocabulary:            len(a.TrainingSet.Vocabulary),
+			TestMessages:          t.MessageTotal,
+			CorrectHam:            t.CorrectHam,
+			CorrectSpam:           t.CorrectSpam,
+			IncorrectHam:          t.IncorrectHam,
+			IncorrectSpam:         t.IncorrectSpam,
+			PercentageCorrectHam:  t.PercentageCorrectHam,
+			PercentageCorrectSpam: t.PercentageCorrectSpam,
+			Accuracy:              t.Accuracy(),
+		}
+		for _, cmp := range comparisons {
+			if cmp.Pipeline == a.Name && cmp.Model == a.Model {
+				r.Significance = &significance{
+					Baseline:    cmp.Baseline,
+					Change:      cmp.Accuracy.Difference,
+					Lower:       cmp.Accuracy.Lower,
+					Upper:       cmp.Accuracy.Upper,
+					BaseOnly:    cmp.McNemar.FirstOnly,
+					ThisOnly:    cmp.McNemar.SecondOnly,
+					PValue:      cmp.McNemar.PValue,
+					Significant: cmp.Significant(),
+				}
+			}
+		}
+		results = append(results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
 
-type Class int
+// compareJSON compares the pipelines for writeEvaluationJSON, telling on
+// stderr when they cannot be compared so it does not mix with the JSON.
+func compareJSON(analyses analysis.Analyses, baseline string, samples int, seed int64) []analysis.Comparison {
+	comparisons, err := analysis.ComparePipelines(analyses, baseline, samples, seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot test significance:", err)
+	}
+	return comparisons
+}
 
-func (c Class) toString() string {
-	switch c {
-	case HamClass:
-		return "ham"
-	case SpamClass:
-		return "spam"
-	default:
-		return ""
+// writeExplanationsJSON writes the classification of the text message by every
+// analysis, with explain set also the word breakdown for the classifiers that
+// can explain it.
+func writeExplanationsJSON(w io.Writer, analyses analysis.Analyses, explain bool) error {
+	type result struct {
+		Analysis    string                `json:"analysis"`
+		Model       string                `json:"model"`
+		Class       string                `json:"class"`
+		Explanation *analysis.Explanation `json:"explanation,omitempty"`
 	}
+	var results []result
+	for _, a := range analyses {
+		r := result{Analysis: a.Name, Model: a.Model, Class: a.FoundClass.String()}
+		if explainer, ok := a.Classifier.(analysis.Explainer); ok && explain {
+			e := explainer.Explain(a.TextMessage)
+			r.Explanation = &e
+		}
+		results = append(results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
 }
 
-type pair struct {
-	class      string
-	lineString string
+func printDiscriminativeWords(ts analysis.TrainingSet, class experiment.Class, top int, ranking analysis.Ranking) {
+	bold := color.New(color.FgGreen, color.Bold)
+	boldBlue := color.New(color.FgHiBlue, color.Bold)
+	bold.Printf("The %d most discriminative %s words (%s)\n", top, strings.ToUpper(class.String()), ranking)
+	for _, ws := range ts.DiscriminativeWords(class, top, ranking) {
+		boldBlue.Printf("Word")
+		fmt.Println("\t\t", ws.Word)
+		boldBlue.Printf("Score")
+		fmt.Printf("\t\t %.4f\n", ws.Score)
+	}
 }
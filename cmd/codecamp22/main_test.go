@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/analysis"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+func TestDispatch(t *testing.T) {
+	var ran []string
+	commands := []command{
+		{"train", "train", func(args []string) { ran = append(ran, "train "+strings.Join(args, " ")) }},
+		{"eval", "evaluate", func(args []string) { ran = append(ran, "eval "+strings.Join(args, " ")) }},
+	}
+	var stderr bytes.Buffer
+	if status := dispatch(commands, []string{"eval", "-seed", "7"}, &stderr); status != 0 || len(ran) != 1 || ran[0] != "eval -seed 7" {
+		t.Errorf("running eval: expected status 0 and eval run with its flags, got %d and %q", status, ran)
+	}
+
+	for _, args := range [][]string{nil, {"help"}, {"-h"}, {"evaluate"}} {
+		ran, stderr = nil, bytes.Buffer{}
+		if status := dispatch(commands, args, &stderr); status != 2 || ran != nil {
+			t.Errorf("running %q: expected status 2 and no command run, got %d and %q", args, status, ran)
+		}
+		if !strings.Contains(stderr.String(), "Usage: codecamp22") || !strings.Contains(stderr.String(), "\ttrain ") {
+			t.Errorf("running %q: expected the usage with every command, got %q", args, stderr.String())
+		}
+		if unknown := strings.Contains(stderr.String(), "unknown command"); unknown != (len(args) > 0 && args[0] == "evaluate") {
+			t.Errorf("running %q: unexpected output %q", args, stderr.String())
+		}
+	}
+}
+
+func TestCommandNames(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range commands {
+		if seen[c.name] || c.name == "help" || c.run == nil {
+			t.Errorf("command %q: expected a unique name and a function to run", c.name)
+		}
+		seen[c.name] = true
+	}
+}
+
+func TestEvalConfig(t *testing.T) {
+	cfg, err := evalConfig([]string{"-file", "sms.csv", "-format", "csv", "-header", "-max-vocab", "50", "-select", "mi",
+		"-train-ratio", "0.5", "-seed", "3", "-stream", "-output", "json", "-runs", "runs"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("parsing eval flags: %s", err)
+	}
+	if cfg.File != "sms.csv" || cfg.Format != "csv" || !cfg.Header || cfg.MaxVocab != 50 || cfg.Select != "mi" ||
+		cfg.TrainRatio != 0.5 || cfg.Seed != 3 || !cfg.Stream || cfg.Output != "json" || cfg.Runs != "runs" {
+		t.Errorf("parsing eval flags: got %+v", cfg)
+	}
+	if cfg.Delimiter != "\t" || cfg.TextColumn != "1" || cfg.Top != 5 {
+		t.Errorf("parsing eval flags: expected the defaults of the flags not given, got %+v", cfg)
+	}
+
+	path := writeConfig(t, "exp.yaml", "dataset:\n  file: sms.csv\n  format: csv\nsplit:\n  train_ratio: 0.8\n  seed: 7\n")
+	cfg, err = evalConfig([]string{"-config", path, "-seed", "9"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("parsing eval flags with a config file: %s", err)
+	}
+	if cfg.File != "sms.csv" || cfg.TrainRatio != 0.8 || cfg.Seed != 9 {
+		t.Errorf("parsing eval flags with a config file: expected the flags to override the file, got %+v", cfg)
+	}
+}
+
+func TestEvalConfigErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-output", "xml"},
+		{"-train-ratio", "many"},
+		{"-config", "missing.yaml"},
+		{"-no-such-flag"},
+	} {
+		if _, err := evalConfig(args, flag.ContinueOnError); err == nil {
+			t.Errorf("parsing eval flags %q: expected an error", args)
+		}
+	}
+}
+
+func TestWordFlags(t *testing.T) {
+	for top, valid := range map[string]bool{"0": true, "3": true, "-1": false, "many": false} {
+		cfg := defaultConfig()
+		fs := flag.NewFlagSet("train", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		cfg.wordFlags(fs)
+		if err := fs.Parse([]string{"-top", top}); (err == nil) != valid {
+			t.Errorf("parsing -top %s: expected valid %t, got %v", top, valid, err)
+		}
+	}
+}
+
+func TestUnstreamable(t *testing.T) {
+	pipelines, models := analysis.DefaultPipelines(), analysis.DefaultModels()
+	if skipped := unstreamable(pipelines, models); !strings.Contains(skipped, "TF-IDF Analysis") || !strings.Contains(skipped, "Logistic Regression") {
+		t.Errorf("streaming the defaults: expected TF-IDF and logistic regression skipped, got %q", skipped)
+	}
+	if skipped := unstreamable(pipelines[:1], models[:1]); skipped != "" {
+		t.Errorf("streaming Naive Bayes without preprocessing: expected nothing skipped, got %q", skipped)
+	}
+}
+
+func TestParseOptionsTrainRatio(t *testing.T) {
+	for ratio, valid := range map[float64]bool{-0.5: false, 0: false, 0.75: true, 1: true, 1.5: false} {
+		cfg := defaultConfig()
+		cfg.TrainRatio = ratio
+		opts, err := cfg.parseOptions()
+		if valid && (err != nil || opts.TrainRatio != ratio) {
+			t.Errorf("train ratio %g: expected it accepted, got %g and %v", ratio, opts.TrainRatio, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("train ratio %g: expected an error", ratio)
+		}
+	}
+}
+
+func TestTestSplit(t *testing.T) {
+	for ratio, valid := range map[float64]bool{0.75: true, 0.99: true, 1: false} {
+		cfg := defaultConfig()
+		cfg.TrainRatio = ratio
+		if err := cfg.testSplit(); (err == nil) != valid {
+			t.Errorf("train ratio %g: expected valid %t, got %v", ratio, valid, err)
+		}
+	}
+}
+
+func TestLearningChartEmptyTestSplit(t *testing.T) {
+	points := []analysis.LearningPoint{{Fraction: .5, TrainingMessages: 2, TrainAccuracy: 1}, {Fraction: 1, TrainingMessages: 4, TrainAccuracy: 1}}
+	if lines := learningChart(points, 4); len(lines) != 7 || !strings.Contains(lines[3], "*") {
+		t.Errorf("charting an empty test split: expected the test accuracy at the bottom, got %q", lines)
+	}
+	points[0].TestAccuracy = math.NaN()
+	if lines := learningChart(points, 4); lines != nil {
+		t.Errorf("charting a NaN accuracy: expected no chart, got %q", lines)
+	}
+}
+
+func TestSearchModel(t *testing.T) {
+	classes := experiment.Classes{Ham: []string{"see you at lunch"}, Spam: []string{"claim your free prize"}}
+	pipelines := analysis.DefaultPipelines()
+	model, err := searchModel(analysis.Candidate{Pipeline: pipelines[1], Alpha: .5}, classes)
+	if err != nil || model.Classify("free prize!") != bayes.SpamClass {
+		t.Errorf("saving the best combination: expected a model that finds spam, got %v", err)
+	}
+	if _, err := searchModel(analysis.Candidate{Pipeline: pipelines[len(pipelines)-1], Alpha: .5}, classes); err == nil {
+		t.Errorf("saving a TF-IDF combination: expected an error")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	if cfg := defaultConfig(); cfg.Runs != "" {
+		t.Errorf("default config: expected eval to record no runs, got %q", cfg.Runs)
+	}
+	path := writeConfig(t, "exp.yaml", "pipelines:\n  - name: Stemmed\n    preprocessors: [stemmer]\n    tfidf: {sublinear: true}\n"+
+		"    selection: {max_vocab: 100, rank: mi}\nclassifiers:\n  - model: logistic-regression\n    epochs: 3\n"+
+		"  - model: naive-bayes\n    prior: class\n")
+	cfg := defaultConfig()
+	if err := readConfigFile(path, &cfg); err != nil {
+		t.Fatalf("reading config: %s", err)
+	}
+	settings := cfg.settings()
+	pipelines, _ := settings["pipelines"].([]interface{})
+	if len(pipelines) != 1 {
+		t.Fatalf("pipeline settings: expected 1 pipeline, got %v", settings["pipelines"])
+	}
+	p := pipelines[0].(map[string]interface{})
+	if names, _ := p["preprocessors"].([]string); len(names) != 1 || names[0] != "stemmer" || p["tfidf"] == nil ||
+		p["selection"].(map[string]interface{})["rank"] != "mi" {
+		t.Errorf("pipeline settings: got %v", p)
+	}
+	models, _ := settings["classifiers"].([]interface{})
+	if len(models) != 2 {
+		t.Fatalf("classifier settings: expected 2 classifiers, got %v", settings["classifiers"])
+	}
+	lr, nb := models[0].(map[string]interface{}), models[1].(map[string]interface{})
+	if lr["model"] != "logistic-regression" || lr["epochs"] != 3 || lr["learning_rate"] != 0.1 {
+		t.Errorf("logistic regression settings: expected 3 epochs and the default learning rate, got %v", lr)
+	}
+	if nb["model"] != "naive-bayes" || nb["alpha"] != 1.0 || nb["prior"] != "class" {
+		t.Errorf("naive bayes settings: expected alpha 1 and the class prior, got %v", nb)
+	}
+
+	defaults := defaultConfig().settings()
+	if pipelines, _ := defaults["pipelines"].([]interface{}); len(pipelines) != len(analysis.DefaultPipelines()) {
+		t.Errorf("default settings: expected every default pipeline, got %v", defaults["pipelines"])
+	}
+}
+
+func TestWriteEvaluationJSON(t *testing.T) {
+	predictions := func(correct ...bool) analysis.TestSet {
+		var set analysis.TestSet
+		for i, c := range correct {
+			p := analysis.Prediction{Case: i, Line: i + 1, Class: experiment.HamClass}
+			if !c {
+				p.Predicted = experiment.SpamClass
+			}
+			set.Predictions = append(set.Predictions, p)
+		}
+		return set
+	}
+	analyses := analysis.Analyses{
+		{Name: "Default", Model: "Naive Bayes", TestSet: predictions(true, false, false)},
+		{Name: "Stemmer", Model: "Naive Bayes", TestSet: predictions(true, true, false)},
+	}
+	comparisons, err := analysis.ComparePipelines(analyses, "", 100, 1)
+	if err != nil {
+		t.Fatalf("comparing pipelines: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := writeEvaluationJSON(&buf, analyses, comparisons); err != nil {
+		t.Fatalf("writing json: %s", err)
+	}
+	var results []struct {
+		Analysis     string
+		Significance *struct {
+			Baseline string
+			ThisOnly int
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &results); err != nil {
+		t.Fatalf("reading the json back: %s", err)
+	}
+	if len(results) != 2 || results[0].Significance != nil || results[1].Significance == nil ||
+		results[1].Significance.Baseline != "Default" || results[1].Significance.ThisOnly != 1 {
+		t.Errorf("writing json: expected the significance of Stemmer against Default, got %s", buf.String())
+	}
+}
@@ -0,0 +1,102 @@
+package parse
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+func scanCSVFile(filename string, opts Options, c *checker) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filename, err)
+	}
+	defer file.Close()
+	return scanCSV(file, opts, c)
+}
+
+// ScanCSV reads RFC 4180 CSV, taking the label and text from the columns
+// selected in the options. Quoted fields may contain delimiters and line breaks.
+func ScanCSV(reader io.Reader, opts Options, fn func(Record) error) (Report, error) {
+	c := newChecker(opts, fn)
+	err := scanCSV(reader, opts, c)
+	return c.report, err
+}
+
+func scanCSV(reader io.Reader, opts Options, c *checker) error {
+	labelColumn, textColumn := opts.LabelColumn, opts.TextColumn
+	if labelColumn == "" {
+		labelColumn = "0"
+	}
+	if textColumn == "" {
+		textColumn = "1"
+	}
+	labelIndex, labelErr := strconv.Atoi(labelColumn)
+	textIndex, textErr := strconv.Atoi(textColumn)
+	header := opts.Header || labelErr != nil || textErr != nil
+
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+	for first := true; ; first = false {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading csv: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+		if first && header {
+			if labelErr != nil {
+				if labelIndex, err = columnIndex(row, labelColumn); err != nil {
+					return err
+				}
+			}
+			if textErr != nil {
+				if textIndex, err = columnIndex(row, textColumn); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if labelIndex >= len(row) || textIndex >= len(row) {
+			detail := fmt.Sprintf("got %d columns", len(row))
+			if err := c.problem(Diagnostic{Line: line, Issue: Malformed, Detail: detail}); err != nil {
+				return err
+			}
+			continue
+		}
+		label := strings.TrimSpace(row[labelIndex])
+		if label == "" {
+			if err := c.problem(Diagnostic{Line: line, Issue: MissingLabel}); err != nil {
+				return err
+			}
+			continue
+		}
+		thisClass, err := experiment.ClassType(label)
+		if err != nil {
+			if err := c.problem(Diagnostic{Line: line, Issue: InvalidLabel, Detail: truncate(label)}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.record(Record{Line: line, Class: thisClass, Text: row[textIndex]}); err != nil {
+			return err
+		}
+	}
+}
+
+func columnIndex(header []string, name string) (int, error) {
+	for i, column := range header {
+		if strings.TrimSpace(column) == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no column %q in csv header", name)
+}
@@ -0,0 +1,237 @@
+package parse
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Dedup tells which repeated messages to remove from a dataset.
+type Dedup int
+
+const (
+	// KeepDuplicates reports exact duplicates but keeps them
+	KeepDuplicates Dedup = iota
+	// RemoveExact removes messages with the same text as an earlier one
+	RemoveExact
+	// RemoveNear also removes messages whose SimHash is within
+	// NearDistance bits of an earlier one
+	RemoveNear
+)
+
+// DefaultNearDistance is the number of differing SimHash bits up to which two
+// messages are near duplicates.
+const DefaultNearDistance = 3
+
+func (d Dedup) String() string {
+	switch d {
+	case KeepDuplicates:
+		return "none"
+	case RemoveExact:
+		return "exact"
+	case RemoveNear:
+		return "near"
+	default:
+		return ""
+	}
+}
+
+func DedupType(str string) (Dedup, error) {
+	switch str {
+	case KeepDuplicates.String():
+		return KeepDuplicates, nil
+	case RemoveExact.String():
+		return RemoveExact, nil
+	case RemoveNear.String():
+		return RemoveNear, nil
+	default:
+		return KeepDuplicates, fmt.Errorf("invalid deduplication: %s", str)
+	}
+}
+
+// SimHash is a 64 bit fingerprint of the lower cased words of the text.
+// Texts that share most of their words have fingerprints that differ in few
+// bits.
+func SimHash(text string) uint64 {
+	var weights [64]int
+	for _, word := range tokens(text) {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var hash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+func tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func textHash(text string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return h.Sum64()
+}
+
+// duplicateIndex finds earlier messages with the same text, or with a SimHash
+// within distance bits. The fingerprints are split into distance+1 blocks:
+// two fingerprints that differ in at most distance bits are equal in at least
+// one block, so only messages sharing a block are compared.
+type duplicateIndex struct {
+	near     bool
+	distance int
+	exact    map[uint64]Record
+	blocks   map[[2]uint64][]int
+	hashes   []uint64
+	records  []Record
+}
+
+func newDuplicateIndex(d Dedup, distance int) *duplicateIndex {
+	if distance <= 0 {
+		distance = DefaultNearDistance
+	}
+	return &duplicateIndex{
+		near:     d == RemoveNear,
+		distance: distance,
+		exact:    make(map[uint64]Record),
+		blocks:   make(map[[2]uint64][]int),
+	}
+}
+
+// find returns the earlier record that r duplicates, and whether it is an
+// exact copy.
+func (x *duplicateIndex) find(r Record) (Record, bool, bool) {
+	if first, exists := x.exact[textHash(r.Text)]; exists {
+		return first, true, true
+	}
+	if !x.near {
+		return Record{}, false, false
+	}
+	hash := SimHash(r.Text)
+	for _, key := range x.keys(hash) {
+		for _, i := range x.blocks[key] {
+			if bits.OnesCount64(hash^x.hashes[i]) <= x.distance {
+				return x.records[i], false, true
+			}
+		}
+	}
+	return Record{}, false, false
+}
+
+func (x *duplicateIndex) add(r Record) {
+	x.exact[textHash(r.Text)] = Record{Line: r.Line, Source: r.Source, Class: r.Class}
+	if !x.near {
+		return
+	}
+	hash := SimHash(r.Text)
+	for _, key := range x.keys(hash) {
+		x.blocks[key] = append(x.blocks[key], len(x.hashes))
+	}
+	x.hashes = append(x.hashes, hash)
+	x.records = append(x.records, Record{Line: r.Line, Source: r.Source, Class: r.Class})
+}
+
+// size is the number of messages in the index.
+func (x *duplicateIndex) size() int {
+	return len(x.exact)
+}
+
+func (x *duplicateIndex) keys(hash uint64) [][2]uint64 {
+	n := x.distance + 1
+	if n > 64 {
+		n = 64
+	}
+	keys := make([][2]uint64, n)
+	for b := 0; b < n; b++ {
+		from, to := b*64/n, (b+1)*64/n
+		mask := uint64(1)<<(to-from) - 1
+		if to-from == 64 {
+			mask = ^uint64(0)
+		}
+		keys[b] = [2]uint64{uint64(b), hash >> from & mask}
+	}
+	return keys
+}
+
+// Leakage counts the duplicates that Deduplicate removed from an experiment.
+type Leakage struct {
+	// Training is the number of training messages that repeat an earlier one
+	Training int
+	// Test is the number of test cases that repeat an earlier test case
+	Test int
+	// CrossSplit is the number of test cases that repeat a training message
+	CrossSplit int
+}
+
+// Deduplicate removes repeated messages from an experiment that is already
+// split, keeping the split of everything else, so the accuracy on the result
+// can be compared to the accuracy on the original. Test cases that repeat a
+// training message are removed, since they inflate the accuracy.
+func Deduplicate(ex experiment.Experiment, d Dedup, distance int) (experiment.Experiment, Leakage) {
+	var leakage Leakage
+	if d == KeepDuplicates {
+		return ex, leakage
+	}
+	training := newDuplicateIndex(d, distance)
+	out := ex
+	out.Classes = experiment.Classes{}
+	keep := func(class experiment.Class, text string) bool {
+		r := Record{Class: class, Text: text}
+		if _, _, found := training.find(r); found {
+			leakage.Training++
+			return false
+		}
+		training.add(r)
+		return true
+	}
+	for _, msg := range ex.Classes.Ham {
+		if keep(experiment.HamClass, msg) {
+			out.Classes.Ham = append(out.Classes.Ham, msg)
+		}
+	}
+	for _, msg := range ex.Classes.Spam {
+		if keep(experiment.SpamClass, msg) {
+			out.Classes.Spam = append(out.Classes.Spam, msg)
+		}
+	}
+
+	test := newDuplicateIndex(d, distance)
+	out.Test.Cases = nil
+	for _, tc := range ex.Test.Cases {
+		r := Record{Line: tc.Line, Class: tc.Class, Text: tc.Text}
+		if _, _, found := training.find(r); found {
+			leakage.CrossSplit++
+			continue
+		}
+		if _, _, found := test.find(r); found {
+			leakage.Test++
+			continue
+		}
+		test.add(r)
+		out.Test.Cases = append(out.Test.Cases, tc)
+	}
+	return out, leakage
+}
+
+func (l Leakage) String() string {
+	return fmt.Sprintf("removed %d repeated training messages, %d repeated test cases and %d test cases that repeat a training message",
+		l.Training, l.Test, l.CrossSplit)
+}
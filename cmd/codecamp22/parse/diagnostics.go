@@ -0,0 +1,199 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Issue is a kind of problem found in a dataset.
+type Issue int
+
+const (
+	// MissingLabel is a line without a label, or without a delimiter
+	MissingLabel Issue = iota
+	// Malformed is a row or message that cannot be read at all
+	Malformed
+	// InvalidLabel is a label other than ham or spam
+	InvalidLabel
+	// EmptyText is a labeled message without text
+	EmptyText
+	// Duplicate is a message with the same text as an earlier one. It is
+	// only removed when deduplicating.
+	Duplicate
+	// NearDuplicate is a message with nearly the same words as an earlier
+	// one, found and removed when deduplicating near duplicates
+	NearDuplicate
+	// LabelConflict is a message with the same text as an earlier one but a
+	// different label. It is handled like a duplicate.
+	LabelConflict
+)
+
+func (i Issue) String() string {
+	switch i {
+	case MissingLabel:
+		return "missing label"
+	case Malformed:
+		return "malformed"
+	case InvalidLabel:
+		return "invalid label"
+	case EmptyText:
+		return "empty text"
+	case Duplicate:
+		return "duplicate"
+	case NearDuplicate:
+		return "near duplicate"
+	case LabelConflict:
+		return "label conflict"
+	default:
+		return ""
+	}
+}
+
+// Diagnostic is a problem found on a line of the original dataset.
+type Diagnostic struct {
+	Line   int
+	Source string
+	Issue  Issue
+	// Detail explains the problem, e.g. the invalid label
+	Detail string
+}
+
+func (d Diagnostic) Error() string {
+	if d.Detail == "" {
+		return fmt.Sprintf("%s: %s", location(d.Source, d.Line), d.Issue)
+	}
+	return fmt.Sprintf("%s: %s: %s", location(d.Source, d.Line), d.Issue, d.Detail)
+}
+
+func location(source string, line int) string {
+	if source == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s line %d", source, line)
+}
+
+// Report summarizes what happened while reading a dataset.
+type Report struct {
+	// Records is the number of messages read
+	Records int
+	// Skipped is the number of lines without a label or that were malformed
+	Skipped      int
+	InvalidLabel int
+	EmptyText    int
+	Duplicate    int
+	// NearDuplicate is only counted when deduplicating near duplicates
+	NearDuplicate int
+	// LabelConflict is the number of duplicates labeled differently than
+	// the message they repeat. They are also counted in Duplicate.
+	LabelConflict int
+	// Removed is the number of duplicates left out by deduplication
+	Removed     int
+	Diagnostics []Diagnostic
+	// Unlisted is the number of problems that were counted but left out of
+	// Diagnostics, because it already held Options.MaxDiagnostics of them
+	Unlisted int
+}
+
+// add counts the diagnostic, listing it unless max are listed already. A max
+// of 0 lists every diagnostic.
+func (r *Report) add(d Diagnostic, max int) {
+	switch d.Issue {
+	case MissingLabel, Malformed:
+		r.Skipped++
+	case InvalidLabel:
+		r.InvalidLabel++
+	case EmptyText:
+		r.EmptyText++
+	case Duplicate:
+		r.Duplicate++
+	case NearDuplicate:
+		r.NearDuplicate++
+	case LabelConflict:
+		r.Duplicate++
+		r.LabelConflict++
+	}
+	if max > 0 && len(r.Diagnostics) >= max {
+		r.Unlisted++
+		return
+	}
+	r.Diagnostics = append(r.Diagnostics, d)
+}
+
+func (r Report) String() string {
+	s := fmt.Sprintf("read %d messages, skipped %d lines without a label, %d with an invalid label and %d with empty text, found %d duplicates",
+		r.Records, r.Skipped, r.InvalidLabel, r.EmptyText, r.Duplicate)
+	if r.NearDuplicate > 0 {
+		s += fmt.Sprintf(" and %d near duplicates", r.NearDuplicate)
+	}
+	if r.LabelConflict > 0 {
+		s += fmt.Sprintf(", %d with conflicting labels", r.LabelConflict)
+	}
+	if r.Removed > 0 {
+		s += fmt.Sprintf(", removed %d", r.Removed)
+	}
+	return s
+}
+
+// checker sits between a format scanner and the caller: it collects the
+// diagnostics of the scanner, looks for duplicates, and passes valid records on.
+type checker struct {
+	strict bool
+	fn     func(Record) error
+	report Report
+	dedup  Dedup
+	// seen holds the messages read so far, at most maxSeen of them when it
+	// is above zero
+	seen    *duplicateIndex
+	maxSeen int
+	// maxDiagnostics is the most diagnostics listed in the report, all when zero
+	maxDiagnostics int
+}
+
+func newChecker(opts Options, fn func(Record) error) *checker {
+	return &checker{
+		strict:         opts.Strict,
+		fn:             fn,
+		dedup:          opts.Dedup,
+		seen:           newDuplicateIndex(opts.Dedup, opts.NearDistance),
+		maxSeen:        opts.MaxSeen,
+		maxDiagnostics: opts.MaxDiagnostics,
+	}
+}
+
+// problem adds the diagnostic to the report. In strict mode it is returned
+// as an error that stops the scan.
+func (c *checker) problem(d Diagnostic) error {
+	c.report.add(d, c.maxDiagnostics)
+	if c.strict {
+		return d
+	}
+	return nil
+}
+
+// record passes a labeled message on, unless its text is empty or it is a
+// duplicate that is removed.
+func (c *checker) record(r Record) error {
+	if strings.TrimSpace(r.Text) == "" {
+		return c.problem(Diagnostic{Line: r.Line, Source: r.Source, Issue: EmptyText})
+	}
+	if first, exact, found := c.seen.find(r); found {
+		if exact && first.Class != r.Class {
+			c.report.add(Diagnostic{Line: r.Line, Source: r.Source, Issue: LabelConflict,
+				Detail: fmt.Sprintf("labeled %s, but %s on %s", r.Class, first.Class, location(first.Source, first.Line))}, c.maxDiagnostics)
+		} else if exact {
+			c.report.add(Diagnostic{Line: r.Line, Source: r.Source, Issue: Duplicate,
+				Detail: "same text as " + location(first.Source, first.Line)}, c.maxDiagnostics)
+		} else {
+			c.report.add(Diagnostic{Line: r.Line, Source: r.Source, Issue: NearDuplicate,
+				Detail: "similar to " + location(first.Source, first.Line)}, c.maxDiagnostics)
+		}
+		if c.dedup != KeepDuplicates {
+			c.report.Removed++
+			return nil
+		}
+	} else if c.maxSeen <= 0 || c.seen.size() < c.maxSeen {
+		c.seen.add(r)
+	}
+	c.report.Records++
+	return c.fn(r)
+}
@@ -0,0 +1,188 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Format is the layout of a dataset.
+type Format int
+
+const (
+	// Delimited is one "label<delimiter>text" message per line
+	Delimited Format = iota
+	// CSV is RFC 4180 comma separated values with a label and a text column
+	CSV
+	// JSONLines is one {"label": ..., "text": ...} object per line
+	JSONLines
+	// Mbox is a directory with a folder per label holding mbox or .eml files
+	Mbox
+)
+
+func (f Format) String() string {
+	switch f {
+	case Delimited:
+		return "delimited"
+	case CSV:
+		return "csv"
+	case JSONLines:
+		return "jsonl"
+	case Mbox:
+		return "mbox"
+	default:
+		return ""
+	}
+}
+
+func FormatType(str string) (Format, error) {
+	switch str {
+	case Delimited.String():
+		return Delimited, nil
+	case CSV.String():
+		return CSV, nil
+	case JSONLines.String():
+		return JSONLines, nil
+	case Mbox.String():
+		return Mbox, nil
+	default:
+		return Delimited, fmt.Errorf("invalid format: %s", str)
+	}
+}
+
+// Options describes how to read a dataset.
+type Options struct {
+	Format Format
+	// Delimiter separates the label from the text in the Delimited format
+	Delimiter string
+	// LabelColumn and TextColumn select the CSV columns, either by zero based
+	// index or by name from the header row
+	LabelColumn string
+	TextColumn  string
+	// Header tells whether the first CSV row holds column names. It is
+	// implied when a column is selected by name.
+	Header bool
+	// Strict stops reading at the first invalid line instead of skipping it
+	Strict bool
+	// Dedup removes repeated messages as they are read, keeping the first
+	Dedup Dedup
+	// NearDistance is the most SimHash bits in which near duplicates differ,
+	// DefaultNearDistance when zero
+	NearDistance int
+	// MaxSeen is the most distinct messages that later messages are checked
+	// against for duplicates, and MaxDiagnostics the most problems the report
+	// lists, so reading a corpus of any size takes bounded memory. Zero
+	// keeps every message and lists every problem.
+	MaxSeen        int
+	MaxDiagnostics int
+	// Split holds part of the messages out as test cases when loading. The
+	// training split gets TrainRatio of them, DefaultTrainRatio when zero.
+	Split      bool
+	TrainRatio float64
+	// Seed shuffles the messages before they are split, a random seed when zero
+	Seed int64
+}
+
+func (o Options) delimiter() string {
+	if o.Delimiter == "" {
+		return "\t"
+	}
+	return o.Delimiter
+}
+
+func (o Options) trainRatio() float64 {
+	if o.TrainRatio <= 0 || o.TrainRatio > 1 {
+		return DefaultTrainRatio
+	}
+	return o.TrainRatio
+}
+
+// ScanFormat reads the dataset at path in the format of the options and calls
+// fn for every labeled message, reporting the lines it could not use.
+func ScanFormat(path string, opts Options, fn func(Record) error) (Report, error) {
+	c := newChecker(opts, fn)
+	var err error
+	switch opts.Format {
+	case CSV:
+		err = scanCSVFile(path, opts, c)
+	case JSONLines:
+		err = scanJSONLinesFile(path, c)
+	case Mbox:
+		err = scanMailDir(path, c)
+	default:
+		err = scanDelimitedFile(path, opts.delimiter(), c)
+	}
+	return c.report, err
+}
+
+// LoadFile reads the dataset at path in the format of the options into an
+// experiment. The report lists every line that was skipped, with its line
+// number in the original file.
+func LoadFile(path string, opts Options) (experiment.Experiment, Report, error) {
+	var records []Record
+	report, err := ScanFormat(path, opts, func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		return experiment.Experiment{}, report, err
+	}
+	return fromRecords(records, opts), report, nil
+}
+
+// Load is LoadFile for a dataset that is not a directory.
+func Load(reader io.Reader, opts Options) (experiment.Experiment, Report, error) {
+	var records []Record
+	collect := func(r Record) error {
+		records = append(records, r)
+		return nil
+	}
+	var report Report
+	var err error
+	switch opts.Format {
+	case CSV:
+		report, err = ScanCSV(reader, opts, collect)
+	case JSONLines:
+		report, err = ScanJSONLines(reader, opts, collect)
+	case Mbox:
+		return experiment.Experiment{}, report, fmt.Errorf("loading %s: needs a directory", opts.Format)
+	default:
+		report, err = Scan(reader, opts, collect)
+	}
+	if err != nil {
+		return experiment.Experiment{}, report, err
+	}
+	return fromRecords(records, opts), report, nil
+}
+
+// fromRecords shuffles the records and splits them into training messages
+// and test cases.
+func fromRecords(records []Record, opts Options) experiment.Experiment {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(records), func(i, j int) { records[i], records[j] = records[j], records[i] })
+	numberToTrain := len(records)
+	if opts.Split {
+		numberToTrain = int(float64(len(records)) * opts.trainRatio())
+	}
+
+	var ex experiment.Experiment
+	for i, r := range records {
+		if i >= numberToTrain {
+			ex.Test.Cases = append(ex.Test.Cases, experiment.TestCase{Class: r.Class, Text: r.Text, Line: r.Line, Source: r.Source})
+			continue
+		}
+		if r.Class == experiment.SpamClass {
+			ex.Classes.Spam = append(ex.Classes.Spam, r.Text)
+		} else {
+			ex.Classes.Ham = append(ex.Classes.Ham, r.Text)
+		}
+	}
+	return ex
+}
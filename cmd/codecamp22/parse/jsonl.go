@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+func scanJSONLinesFile(filename string, c *checker) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filename, err)
+	}
+	defer file.Close()
+	return scanJSONLines(file, c)
+}
+
+// ScanJSONLines reads one {"label": "spam", "text": "..."} object per line.
+func ScanJSONLines(reader io.Reader, opts Options, fn func(Record) error) (Report, error) {
+	c := newChecker(opts, fn)
+	err := scanJSONLines(reader, c)
+	return c.report, err
+}
+
+func scanJSONLines(reader io.Reader, c *checker) error {
+	lines := newLineReader(reader)
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading line %d: %w", lines.number, err)
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var message struct {
+			Label string `json:"label"`
+			Text  string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			if err := c.problem(Diagnostic{Line: lines.number, Issue: Malformed, Detail: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if message.Label == "" {
+			if err := c.problem(Diagnostic{Line: lines.number, Issue: MissingLabel}); err != nil {
+				return err
+			}
+			continue
+		}
+		thisClass, err := experiment.ClassType(message.Label)
+		if err != nil {
+			if err := c.problem(Diagnostic{Line: lines.number, Issue: InvalidLabel, Detail: truncate(message.Label)}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.record(Record{Line: lines.number, Class: thisClass, Text: message.Text}); err != nil {
+			return err
+		}
+	}
+}
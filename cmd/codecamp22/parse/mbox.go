@@ -0,0 +1,108 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/email"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// scanMailDir reads a directory with a "ham" and a "spam" folder, labeling
+// every message by the folder it is in. The folders hold .eml files with one
+// message each, or mbox files with many.
+func scanMailDir(dir string, c *checker) error {
+	found := false
+	for _, class := range []experiment.Class{experiment.HamClass, experiment.SpamClass} {
+		folder := filepath.Join(dir, class.String())
+		if info, err := os.Stat(folder); err != nil || !info.IsDir() {
+			continue
+		}
+		found = true
+		err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+			return scanMailFile(path, class, c)
+		})
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", folder, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("reading %s: no ham or spam folder", dir)
+	}
+	return nil
+}
+
+// scanMailFile reads a single message, or every message of an mbox file.
+func scanMailFile(path string, class experiment.Class, c *checker) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	emit := func(line int, raw string) error {
+		text, err := mailText(raw)
+		if err != nil {
+			return c.problem(Diagnostic{Line: line, Source: path, Issue: Malformed, Detail: err.Error()})
+		}
+		return c.record(Record{Line: line, Class: class, Text: text, Source: path})
+	}
+
+	lines := newLineReader(file)
+	var message strings.Builder
+	start, mbox := 1, false
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading line %d of %s: %w", lines.number, path, err)
+		}
+		// in mbox files every message starts with a "From " line, the
+		// first one included
+		if lines.number == 1 && strings.HasPrefix(line, "From ") {
+			mbox = true
+		}
+		if mbox && strings.HasPrefix(line, "From ") {
+			if message.Len() > 0 {
+				if err := emit(start, message.String()); err != nil {
+					return err
+				}
+				message.Reset()
+			}
+			start = lines.number + 1
+			continue
+		}
+		// mbox escapes "From " at the start of body lines as ">From "
+		if mbox && strings.HasPrefix(line, ">From ") {
+			line = line[1:]
+		}
+		message.WriteString(line)
+		message.WriteString("\n")
+	}
+	if message.Len() == 0 {
+		return nil
+	}
+	return emit(start, message.String())
+}
+
+// mailText turns a message into a single line of text with the header
+// tokens of email.Message.Text.
+func mailText(raw string) (string, error) {
+	msg, err := email.Parse(strings.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	return msg.Text(), nil
+}
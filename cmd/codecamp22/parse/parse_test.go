@@ -1,10 +1,14 @@
 package parse_test
 
 import (
+	"errors"
 	"path"
 	"strings"
+	"sync"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
 	"github.com/andreas-holm/codecamp22/cmd/codecamp22/parse"
 )
 
@@ -28,7 +32,7 @@ spam	how now, brown cow?
 	}
 }
 
-const filename = "textMsgs.data"
+const filename = "trainingData.data"
 
 func TestFromFile(t *testing.T) {
 	experiment, err := parse.FromFile(path.Join("..", filename), "\t", false)
@@ -40,3 +44,247 @@ func TestFromFile(t *testing.T) {
 		t.Errorf("counting all types of cases: expected 5574, got %d", totalCasesAllTypes)
 	}
 }
+
+func TestScanLongLine(t *testing.T) {
+	long := strings.Repeat("free ", 100000)
+	text := strings.NewReader("ham\tsee you soon\nspam\t" + long + "\r\nham\tok\n")
+	var records []parse.Record
+	_, err := parse.Scan(text, parse.Options{Delimiter: "\t"}, func(r parse.Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanning: %s", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("counting records: expected 3, got %d", len(records))
+	}
+	if records[1].Text != long || records[1].Line != 2 {
+		t.Errorf("long record: expected %d characters on line 2, got %d on line %d", len(long), len(records[1].Text), records[1].Line)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("disk on fire") }
+
+func TestScanReadError(t *testing.T) {
+	_, err := parse.Scan(failingReader{}, parse.Options{}, func(parse.Record) error { return nil })
+	if err == nil {
+		t.Errorf("scanning a failing reader: expected an error")
+	}
+}
+
+func TestScanCSV(t *testing.T) {
+	text := strings.NewReader("id,text,label\n1,\"free, as in \"\"beer\"\"\",spam\n2,\"see you\nlater\",ham\n")
+	var records []parse.Record
+	_, err := parse.ScanCSV(text, parse.Options{LabelColumn: "label", TextColumn: "text"}, func(r parse.Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanning csv: %s", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("counting records: expected 2, got %d", len(records))
+	}
+	if records[0].Text != `free, as in "beer"` || records[1].Text != "see you\nlater" {
+		t.Errorf("quoted fields: got %q and %q", records[0].Text, records[1].Text)
+	}
+	if records[1].Line != 3 {
+		t.Errorf("line of second record: expected 3, got %d", records[1].Line)
+	}
+}
+
+func TestScanJSONLines(t *testing.T) {
+	const text = `{"label":"spam","text":"win"}` + "\n" + `{"label":"eggs","text":"x"}` + "\n" + `{"label":"ham","text":"hi"}` + "\n"
+	var records []parse.Record
+	report, err := parse.ScanJSONLines(strings.NewReader(text), parse.Options{}, func(r parse.Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanning leniently: %s", err)
+	}
+	if len(records) != 2 || report.InvalidLabel != 1 {
+		t.Errorf("lenient scan: expected 2 records and 1 invalid label, got %d and %d", len(records), report.InvalidLabel)
+	}
+
+	_, err = parse.ScanJSONLines(strings.NewReader(text), parse.Options{Strict: true}, func(parse.Record) error { return nil })
+	if err == nil {
+		t.Errorf("scanning an invalid label strictly: expected an error")
+	}
+}
+
+func TestLoadReport(t *testing.T) {
+	text := strings.NewReader(`ham	see you soon
+no label here
+spamm	free prize
+spam	
+ham	see you soon
+spam	win cash
+`)
+	ex, report, err := parse.Load(text, parse.Options{Delimiter: "\t"})
+	if err != nil {
+		t.Fatalf("loading leniently: %s", err)
+	}
+	if len(ex.Classes.Ham)+len(ex.Classes.Spam) != 3 {
+		t.Errorf("counting messages: expected 3, got %d", len(ex.Classes.Ham)+len(ex.Classes.Spam))
+	}
+	if report.Records != 3 || report.Skipped != 1 || report.InvalidLabel != 1 || report.EmptyText != 1 || report.Duplicate != 1 {
+		t.Errorf("report: got %+v", report)
+	}
+	expectedLines := []int{2, 3, 4, 5}
+	for i, d := range report.Diagnostics {
+		if i >= len(expectedLines) || d.Line != expectedLines[i] {
+			t.Errorf("diagnostic %d: expected line %v, got %s", i, expectedLines, d)
+		}
+	}
+
+	_, _, err = parse.Load(strings.NewReader("ham\tok\nspamm\tno\n"), parse.Options{Strict: true})
+	var d parse.Diagnostic
+	if !errors.As(err, &d) || d.Line != 2 || d.Issue != parse.InvalidLabel {
+		t.Errorf("loading strictly: expected an invalid label on line 2, got %v", err)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	text := "ham\tsee you at 5 tonight\nspam\tWIN a free prize now, call 0800 123 today!\nham\tsee you at 5 tonight\nspam\twin a free prize now call 0800 123 today\n"
+	ex, report, err := parse.Load(strings.NewReader(text), parse.Options{Dedup: parse.RemoveExact})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	if len(ex.Classes.Ham)+len(ex.Classes.Spam) != 3 || report.Removed != 1 {
+		t.Errorf("removing exact duplicates: expected 3 messages and 1 removed, got %d and %d",
+			len(ex.Classes.Ham)+len(ex.Classes.Spam), report.Removed)
+	}
+
+	_, report, err = parse.Load(strings.NewReader(text), parse.Options{Dedup: parse.RemoveNear})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	if report.Duplicate != 1 || report.NearDuplicate != 1 || report.Removed != 2 {
+		t.Errorf("removing near duplicates: got %+v", report)
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	ex := experiment.Experiment{
+		Classes: experiment.Classes{
+			Ham:  []string{"see you at 5 tonight", "see you at 5 tonight", "on my way home"},
+			Spam: []string{"win a free prize now"},
+		},
+		Test: experiment.TestSet{Cases: []experiment.TestCase{
+			{Class: experiment.HamClass, Text: "see you at 5 tonight"},
+			{Class: experiment.SpamClass, Text: "claim your cash reward"},
+			{Class: experiment.SpamClass, Text: "claim your cash reward"},
+		}},
+	}
+	deduped, leakage := parse.Deduplicate(ex, parse.RemoveExact, 0)
+	if leakage.Training != 1 || leakage.Test != 1 || leakage.CrossSplit != 1 {
+		t.Errorf("leakage: got %+v", leakage)
+	}
+	if len(deduped.Classes.Ham) != 2 || len(deduped.Test.Cases) != 1 || len(ex.Test.Cases) != 3 {
+		t.Errorf("deduplicating: expected 2 ham and 1 test case, leaving the original alone")
+	}
+}
+
+func TestLabelConflict(t *testing.T) {
+	_, report, err := parse.Load(strings.NewReader("ham\tcall me\nspam\tcall me\n"), parse.Options{})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	if report.LabelConflict != 1 || report.Duplicate != 1 || report.Diagnostics[0].Line != 2 {
+		t.Errorf("label conflicts: got %+v", report)
+	}
+}
+
+func TestLoadConcurrently(t *testing.T) {
+	const text = "ham\tsee you\nspam\twin now\nham\ton my way\nham\tcall me\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(split bool) {
+			defer wg.Done()
+			ex, _, err := parse.Load(strings.NewReader(text), parse.Options{Split: split})
+			if err != nil {
+				t.Errorf("loading: %s", err)
+				return
+			}
+			expected := 0
+			if split {
+				expected = 1
+			}
+			if len(ex.Test.Cases) != expected {
+				t.Errorf("loading with split %t: expected %d test cases, got %d", split, expected, len(ex.Test.Cases))
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestNGrams(t *testing.T) {
+	got := parse.PreprocessNGrams{N: 3}.ProcessMessage("free  prize now")
+	expected := "free prize now free_prize prize_now free_prize_now"
+	if got != expected {
+		t.Errorf("adding n-grams: expected %q, got %q", expected, got)
+	}
+	if got := (parse.PreprocessNGrams{N: 1}).ProcessMessage("free prize"); got != "free prize" {
+		t.Errorf("adding unigrams only: expected the words, got %q", got)
+	}
+}
+
+func TestScanMailDir(t *testing.T) {
+	dir := path.Join("testdata", "mail")
+	var records []parse.Record
+	report, err := parse.ScanFormat(dir, parse.Options{Format: parse.Mbox}, func(r parse.Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanning %s: %s", dir, err)
+	}
+	expected := []parse.Record{
+		{Line: 1, Class: experiment.HamClass, Text: "from:example.org subj:Lunch subj:today? See you at noon.", Source: path.Join(dir, "ham", "lunch.eml")},
+		{Line: 2, Class: experiment.SpamClass, Text: "from:lottery.example subj:You subj:won Claim your prize now. From the prize team", Source: path.Join(dir, "spam", "inbox.mbox")},
+		{Line: 9, Class: experiment.SpamClass, Text: "from:shop.example subj:Cheap subj:pills Buy now", Source: path.Join(dir, "spam", "inbox.mbox")},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("scanning %s: expected %d messages, got %+v", dir, len(expected), records)
+	}
+	for i, r := range expected {
+		if records[i] != r {
+			t.Errorf("message %d: expected %+v, got %+v", i, r, records[i])
+		}
+	}
+	if report.Skipped != 1 || report.Diagnostics[0].Issue != parse.Malformed || report.Diagnostics[0].Source != path.Join(dir, "ham", "broken.eml") {
+		t.Errorf("scanning a malformed message: expected it skipped, got %+v", report)
+	}
+
+	if _, err := parse.ScanFormat(dir, parse.Options{Format: parse.Mbox, Strict: true}, func(parse.Record) error { return nil }); err == nil {
+		t.Errorf("scanning a malformed message strictly: expected an error")
+	}
+	if _, err := parse.ScanFormat(path.Join(dir, "ham"), parse.Options{Format: parse.Mbox}, func(parse.Record) error { return nil }); err == nil {
+		t.Errorf("scanning a directory without ham and spam folders: expected an error")
+	}
+}
+
+func TestScanLimits(t *testing.T) {
+	label := strings.Repeat("é", 40)
+	text := "ham\tsee you\n" + label + "\tfree\nham\tsee you\nspam\twin\nspam\twin\nham\tsee you\n"
+	var records int
+	report, err := parse.Scan(strings.NewReader(text), parse.Options{MaxSeen: 1, MaxDiagnostics: 2}, func(parse.Record) error {
+		records++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanning: %s", err)
+	}
+	if !utf8.ValidString(report.Diagnostics[0].Detail) || !strings.HasSuffix(report.Diagnostics[0].Detail, "...") {
+		t.Errorf("quoting a long label: expected it shortened on a character boundary, got %q", report.Diagnostics[0].Detail)
+	}
+	// only the first message is remembered, so the repeated spam is not found
+	if records != 5 || report.Duplicate != 2 || len(report.Diagnostics) != 2 || report.Unlisted != 1 {
+		t.Errorf("scanning with limits: expected 5 records, 2 duplicates and 1 unlisted problem, got %d and %+v", records, report)
+	}
+}
@@ -12,17 +12,18 @@ type PreprocessStemmer struct{}
 
 func (p PreprocessStemmer) Process(ex *experiment.Experiment) {
 	for i, m := range ex.Classes.Spam {
-		ex.Classes.Spam[i] = p.processMessage(m)
+		ex.Classes.Spam[i] = p.ProcessMessage(m)
 	}
 	for i, m := range ex.Classes.Ham {
-		ex.Classes.Ham[i] = p.processMessage(m)
+		ex.Classes.Ham[i] = p.ProcessMessage(m)
 	}
 	for i, t := range ex.Test.Cases {
-		ex.Test.Cases[i].Text = p.processMessage(t.Text)
+		ex.Test.Cases[i].Text = p.ProcessMessage(t.Text)
 	}
+	ex.TextMessage = p.ProcessMessage(ex.TextMessage)
 }
 
-func (p PreprocessStemmer) processMessage(original string) string {
+func (p PreprocessStemmer) ProcessMessage(original string) string {
 	var words []string
 	for _, word := range strings.Split(original, " ") {
 		stem := porterstemmer.StemString(word)
@@ -35,17 +36,18 @@ type PreprocessRemovePunctuation struct{}
 
 func (p PreprocessRemovePunctuation) Process(ex *experiment.Experiment) {
 	for i, m := range ex.Classes.Spam {
-		ex.Classes.Spam[i] = p.processMessage(m)
+		ex.Classes.Spam[i] = p.ProcessMessage(m)
 	}
 	for i, m := range ex.Classes.Ham {
-		ex.Classes.Ham[i] = p.processMessage(m)
+		ex.Classes.Ham[i] = p.ProcessMessage(m)
 	}
 	for i, t := range ex.Test.Cases {
-		ex.Test.Cases[i].Text = p.processMessage(t.Text)
+		ex.Test.Cases[i].Text = p.ProcessMessage(t.Text)
 	}
+	ex.TextMessage = p.ProcessMessage(ex.TextMessage)
 }
 
-func (p PreprocessRemovePunctuation) processMessage(original string) string {
+func (p PreprocessRemovePunctuation) ProcessMessage(original string) string {
 	reg, _ := regexp.Compile("[^a-zA-Z0-9 ]+")
 
 	return reg.ReplaceAllString(original, "")
@@ -55,17 +57,18 @@ type PreprocessRemoveCommonWords struct{}
 
 func (p PreprocessRemoveCommonWords) Process(ex *experiment.Experiment) {
 	for i, m := range ex.Classes.Spam {
-		ex.Classes.Spam[i] = p.processMessage(m)
+		ex.Classes.Spam[i] = p.ProcessMessage(m)
 	}
 	for i, m := range ex.Classes.Ham {
-		ex.Classes.Ham[i] = p.processMessage(m)
+		ex.Classes.Ham[i] = p.ProcessMessage(m)
 	}
 	for i, t := range ex.Test.Cases {
-		ex.Test.Cases[i].Text = p.processMessage(t.Text)
+		ex.Test.Cases[i].Text = p.ProcessMessage(t.Text)
 	}
+	ex.TextMessage = p.ProcessMessage(ex.TextMessage)
 }
 
-func (p PreprocessRemoveCommonWords) processMessage(original string) string {
+func (p PreprocessRemoveCommonWords) ProcessMessage(original string) string {
 	var words []string
 	for _, word := range strings.Split(original, " ") {
 		if isCommon(word) {
@@ -92,3 +95,35 @@ func isCommon(word string) bool {
 	}
 	return false
 }
+
+// PreprocessNGrams adds the sequences of 2 up to N consecutive words of a
+// message as words of their own, joined by underscores, so "free prize now"
+// with N 2 becomes "free prize now free_prize prize_now". It goes last in a
+// pipeline, after the preprocessors that change the words.
+type PreprocessNGrams struct {
+	N int
+}
+
+func (p PreprocessNGrams) Process(ex *experiment.Experiment) {
+	for i, m := range ex.Classes.Spam {
+		ex.Classes.Spam[i] = p.ProcessMessage(m)
+	}
+	for i, m := range ex.Classes.Ham {
+		ex.Classes.Ham[i] = p.ProcessMessage(m)
+	}
+	for i, t := range ex.Test.Cases {
+		ex.Test.Cases[i].Text = p.ProcessMessage(t.Text)
+	}
+	ex.TextMessage = p.ProcessMessage(ex.TextMessage)
+}
+
+func (p PreprocessNGrams) ProcessMessage(original string) string {
+	words := strings.Fields(original)
+	grams := append([]string(nil), words...)
+	for n := 2; n <= p.N; n++ {
+		for i := 0; i+n <= len(words); i++ {
+			grams = append(grams, strings.Join(words[i:i+n], "_"))
+		}
+	}
+	return strings.Join(grams, " ")
+}
@@ -0,0 +1,183 @@
+package parse
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/experiment"
+)
+
+// Record is one labeled message and the line of the file it was read from.
+type Record struct {
+	Line  int
+	Class experiment.Class
+	Text  string
+	// Source is the file the message was read from, when a dataset is a
+	// directory of files
+	Source string
+}
+
+// Example returns the record as a labeled message for the bayes package.
+func (r Record) Example() bayes.Example {
+	class := bayes.HamClass
+	if r.Class == experiment.SpamClass {
+		class = bayes.SpamClass
+	}
+	return bayes.Example{Class: class, Text: r.Text}
+}
+
+// ScanFile opens the delimited file and scans it with Scan.
+func ScanFile(filename string, opts Options, fn func(Record) error) (Report, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Report{}, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	defer file.Close()
+	return Scan(file, opts, fn)
+}
+
+// Scan reads "label<delimiter>text" lines one at a time and calls fn for each
+// labeled message, so a corpus of any size can be processed without holding
+// it in memory. Lines may be of any length. Invalid lines are reported, and
+// in strict mode the first one stops the scan. Scanning also stops at the
+// first read error or error returned by fn.
+func Scan(reader io.Reader, opts Options, fn func(Record) error) (Report, error) {
+	c := newChecker(opts, fn)
+	err := scanDelimited(reader, opts.delimiter(), c)
+	return c.report, err
+}
+
+func scanDelimitedFile(filename, delimiter string, c *checker) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filename, err)
+	}
+	defer file.Close()
+	return scanDelimited(file, delimiter, c)
+}
+
+func scanDelimited(reader io.Reader, delimiter string, c *checker) error {
+	lines := newLineReader(reader)
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading line %d: %w", lines.number, err)
+		}
+		// skip empty lines
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, delimiter, 2)
+		// eliminate lines without a class
+		if len(parts) < 2 {
+			if err := c.problem(Diagnostic{Line: lines.number, Issue: MissingLabel, Detail: truncate(line)}); err != nil {
+				return err
+			}
+			continue
+		}
+		thisClass, err := experiment.ClassType(parts[0])
+		if err != nil {
+			if err := c.problem(Diagnostic{Line: lines.number, Issue: InvalidLabel, Detail: truncate(parts[0])}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.record(Record{Line: lines.number, Class: thisClass, Text: parts[1]}); err != nil {
+			return err
+		}
+	}
+}
+
+// truncate shortens offending input quoted in a diagnostic, without cutting
+// a character in two.
+func truncate(text string) string {
+	const max = 60
+	if len(text) <= max {
+		return text
+	}
+	end := max
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end] + "..."
+}
+
+// lineReader reads lines of unlimited length, unlike bufio.Scanner which
+// gives up on lines longer than its buffer.
+type lineReader struct {
+	reader *bufio.Reader
+	// number is the line number of the line last returned by next
+	number int
+}
+
+func newLineReader(reader io.Reader) *lineReader {
+	return &lineReader{reader: bufio.NewReader(reader)}
+}
+
+// next returns the next line without its line ending, or io.EOF after the last one.
+func (l *lineReader) next() (string, error) {
+	line, err := l.reader.ReadString('\n')
+	if errors.Is(err, io.EOF) && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	l.number++
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Limits of what is kept in memory to find duplicates and report problems
+// when a corpus is read in a single pass, see Options.MaxSeen and
+// Options.MaxDiagnostics, and of the test messages sampled by a Reservoir.
+const (
+	StreamMaxSeen        = 100000
+	StreamMaxDiagnostics = 1000
+	StreamTestSample     = 10000
+)
+
+// InTraining decides from a hash of the message text whether it belongs to
+// the training split, so every message lands in the same split on every run
+// without knowing the size of the corpus up front.
+func InTraining(text string, ratio float64) bool {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return float64(h.Sum64()%10000) < ratio*10000
+}
+
+// Reservoir keeps a uniformly random sample of at most Size test cases out of
+// a stream of unknown length.
+type Reservoir struct {
+	Size  int
+	Cases []experiment.TestCase
+
+	seen int
+	rng  *rand.Rand
+}
+
+func NewReservoir(size int, seed int64) *Reservoir {
+	return &Reservoir{Size: size, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Add offers a test case to the sample. A Size of 0 or less keeps every case.
+func (r *Reservoir) Add(tc experiment.TestCase) {
+	r.seen++
+	if r.Size <= 0 || len(r.Cases) < r.Size {
+		r.Cases = append(r.Cases, tc)
+		return
+	}
+	if i := r.rng.Intn(r.seen); i < r.Size {
+		r.Cases[i] = tc
+	}
+}
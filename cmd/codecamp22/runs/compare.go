@@ -0,0 +1,84 @@
+package runs
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Delta is the change in the metrics of a pipeline and model from one run to
+// another. Before or After is nil when only one of the runs has the result.
+type Delta struct {
+	Analysis string
+	Model    string
+	Before   *Result
+	After    *Result
+}
+
+// Change is the difference of the metrics, zero unless both runs have them.
+func (d Delta) Change() Metrics {
+	if d.Before == nil || d.After == nil {
+		return Metrics{}
+	}
+	return Metrics{
+		Accuracy:  d.After.Accuracy - d.Before.Accuracy,
+		Precision: d.After.Precision - d.Before.Precision,
+		Recall:    d.After.Recall - d.Before.Recall,
+		F1:        d.After.F1 - d.Before.F1,
+	}
+}
+
+// Compare pairs the results of two runs by pipeline and model, in the order
+// of the first run followed by the results only the second one has.
+func Compare(before, after Run) []Delta {
+	type key struct{ analysis, model string }
+	var deltas []Delta
+	index := make(map[key]int)
+	for i := range before.Results {
+		r := &before.Results[i]
+		index[key{r.Analysis, r.Model}] = len(deltas)
+		deltas = append(deltas, Delta{Analysis: r.Analysis, Model: r.Model, Before: r})
+	}
+	for i := range after.Results {
+		r := &after.Results[i]
+		if j, exists := index[key{r.Analysis, r.Model}]; exists {
+			deltas[j].After = r
+			continue
+		}
+		deltas = append(deltas, Delta{Analysis: r.Analysis, Model: r.Model, After: r})
+	}
+	return deltas
+}
+
+// ConfigChanges lists the settings that differ between two runs, as
+// "name: before -> after", sorted by name.
+func ConfigChanges(before, after Run) []string {
+	names := make(map[string]bool)
+	for name := range before.Config {
+		names[name] = true
+	}
+	for name := range after.Config {
+		names[name] = true
+	}
+	var changes []string
+	for name := range names {
+		b, inBefore := before.Config[name]
+		a, inAfter := after.Config[name]
+		if inBefore && inAfter && reflect.DeepEqual(a, b) {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", name, setting(b, inBefore), setting(a, inAfter)))
+	}
+	if before.Seed != after.Seed {
+		changes = append(changes, fmt.Sprintf("seed: %d -> %d", before.Seed, after.Seed))
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func setting(v interface{}, set bool) string {
+	if !set {
+		return "(unset)"
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,189 @@
+// Package runs records the results of evaluations, so runs with different
+// settings or datasets can be compared later.
+package runs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultDir is where the runs command looks for runs unless another
+// directory is given, and where eval records them when asked to.
+const DefaultDir = ".codecamp22/runs"
+
+// Run is one evaluation: the settings it ran with, the dataset it read and
+// the metrics of every pipeline and model.
+type Run struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Config holds the settings of the run by name
+	Config map[string]interface{} `json:"config"`
+	Seed   int64                  `json:"seed"`
+	// Dataset is the hash of the content of the dataset, see HashDataset
+	Dataset string   `json:"dataset"`
+	Results []Result `json:"results"`
+}
+
+// Result holds the metrics of one pipeline and model on the test split.
+type Result struct {
+	Analysis      string `json:"analysis"`
+	Model         string `json:"model"`
+	Vocabulary    int    `json:"vocabulary"`
+	TestMessages  int    `json:"testMessages"`
+	CorrectHam    int    `json:"correctHam"`
+	CorrectSpam   int    `json:"correctSpam"`
+	IncorrectHam  int    `json:"incorrectHam"`
+	IncorrectSpam int    `json:"incorrectSpam"`
+	Metrics       `json:"metrics"`
+}
+
+// Metrics are the scores of a classifier, with spam as the positive class.
+type Metrics struct {
+	Accuracy  float64 `json:"accuracy"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// MetricsOf scores the counts of a test split. Incorrect ham is spam that was
+// classified as ham, incorrect spam is ham that was classified as spam.
+func MetricsOf(correctHam, correctSpam, incorrectHam, incorrectSpam int) Metrics {
+	ratio := func(n, d int) float64 {
+		if d == 0 {
+			return 0
+		}
+		return float64(n) / float64(d)
+	}
+	m := Metrics{
+		Accuracy:  ratio(correctHam+correctSpam, correctHam+correctSpam+incorrectHam+incorrectSpam),
+		Precision: ratio(correctSpam, correctSpam+incorrectSpam),
+		Recall:    ratio(correctSpam, correctSpam+incorrectHam),
+	}
+	if m.Precision+m.Recall > 0 {
+		m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+	}
+	return m
+}
+
+// Store is a directory with a JSON file for every run.
+type Store struct {
+	Dir string
+}
+
+// Save records the run, giving it an ID from its time when it has none.
+func (s Store) Save(run Run) (Run, error) {
+	if run.Time.IsZero() {
+		run.Time = time.Now()
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return run, fmt.Errorf("saving run: %w", err)
+	}
+	id := run.ID
+	if id == "" {
+		id = run.Time.UTC().Format("20060102-150405")
+	}
+	//Runs started within the same second get a suffix
+	for n := 2; ; n++ {
+		file, err := os.OpenFile(s.path(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, fs.ErrExist) && run.ID == "" {
+			id = fmt.Sprintf("%s-%d", run.Time.UTC().Format("20060102-150405"), n)
+			continue
+		}
+		if err != nil {
+			return run, fmt.Errorf("saving run %s: %w", id, err)
+		}
+		run.ID = id
+		enc := json.NewEncoder(file)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(run); err != nil {
+			file.Close()
+			return run, fmt.Errorf("saving run %s: %w", id, err)
+		}
+		return run, file.Close()
+	}
+}
+
+// Load returns the run with the ID.
+func (s Store) Load(id string) (Run, error) {
+	var run Run
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return run, fmt.Errorf("loading run %s: no such run in %s", id, s.Dir)
+	}
+	if err != nil {
+		return run, fmt.Errorf("loading run %s: %w", id, err)
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("loading run %s: %w", id, err)
+	}
+	return run, nil
+}
+
+// List returns every run in the store, oldest first. A store that does not
+// exist yet is empty.
+func (s Store) List() ([]Run, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listing runs: %w", err)
+	}
+	var list []Run
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		run, err := s.Load(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, run)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	return list, nil
+}
+
+func (s Store) path(id string) string {
+	return filepath.Join(s.Dir, filepath.Base(id)+".json")
+}
+
+// HashDataset hashes the content of the dataset at path, so runs on the same
+// data can be recognized wherever the file is and whatever it is called. A
+// directory is hashed by the paths and contents of all of its files.
+func HashDataset(path string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if p != path {
+			rel, err := filepath.Rel(path, p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		}
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(h, file)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,87 @@
+package runs_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/runs"
+)
+
+func TestStore(t *testing.T) {
+	store := runs.Store{Dir: filepath.Join(t.TempDir(), "runs")}
+	list, err := store.List()
+	if err != nil || len(list) != 0 {
+		t.Fatalf("listing an empty store: expected no runs, got %v, %v", list, err)
+	}
+	at := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	first, err := store.Save(runs.Run{Time: at, Seed: 1, Config: map[string]interface{}{"file": "a.data"}})
+	if err != nil {
+		t.Fatalf("saving a run: %s", err)
+	}
+	second, err := store.Save(runs.Run{Time: at, Seed: 2})
+	if err != nil {
+		t.Fatalf("saving a run: %s", err)
+	}
+	if first.ID != "20220501-120000" || second.ID != "20220501-120000-2" {
+		t.Errorf("saving runs in the same second: expected different ids, got %s and %s", first.ID, second.ID)
+	}
+	loaded, err := store.Load(first.ID)
+	if err != nil || loaded.Seed != 1 || loaded.Config["file"] != "a.data" {
+		t.Errorf("loading a run: expected seed 1 of a.data, got %+v, %v", loaded, err)
+	}
+	if _, err := store.Load("missing"); err == nil {
+		t.Errorf("loading a missing run: expected an error")
+	}
+	list, err = store.List()
+	if err != nil || len(list) != 2 {
+		t.Errorf("listing runs: expected 2 runs, got %d, %v", len(list), err)
+	}
+}
+
+func TestHashDataset(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a, _ := runs.HashDataset(write("a.data", "ham\thello\n"))
+	b, _ := runs.HashDataset(write("b.data", "ham\thello\n"))
+	c, _ := runs.HashDataset(write("c.data", "spam\thello\n"))
+	if a == "" || a != b {
+		t.Errorf("hashing the same content: expected equal hashes, got %s and %s", a, b)
+	}
+	if a == c {
+		t.Errorf("hashing different content: expected different hashes")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	before := runs.Run{Config: map[string]interface{}{"dedup": "none", "file": "a.data"}, Results: []runs.Result{
+		{Analysis: "Baseline", Model: "Naive Bayes", Metrics: runs.MetricsOf(8, 1, 1, 0)},
+		{Analysis: "Stemmed", Model: "Naive Bayes", Metrics: runs.MetricsOf(8, 2, 0, 0)},
+	}}
+	after := runs.Run{Config: map[string]interface{}{"dedup": "exact", "file": "a.data"}, Results: []runs.Result{
+		{Analysis: "Baseline", Model: "Naive Bayes", Metrics: runs.MetricsOf(8, 2, 0, 0)},
+		{Analysis: "Baseline", Model: "Logistic Regression", Metrics: runs.MetricsOf(8, 2, 0, 0)},
+	}}
+	deltas := runs.Compare(before, after)
+	if len(deltas) != 3 {
+		t.Fatalf("comparing runs: expected 3 deltas, got %d", len(deltas))
+	}
+	if change := deltas[0].Change(); math.Abs(change.Accuracy-.1) > 1e-9 || math.Abs(change.Recall-.5) > 1e-9 {
+		t.Errorf("comparing runs: expected accuracy +0.1 and recall +0.5, got %+v", change)
+	}
+	if deltas[1].After != nil || deltas[2].Before != nil {
+		t.Errorf("comparing runs: expected results missing from one run to be nil")
+	}
+	changes := runs.ConfigChanges(before, after)
+	if len(changes) != 1 || changes[0] != "dedup: none -> exact" {
+		t.Errorf("comparing configs: expected the dedup change, got %v", changes)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/feedback"
+)
+
+// MaxRequestBytes is the largest request body the server reads.
+const MaxRequestBytes = 1 << 20
+
+// Server classifies messages over HTTP and folds corrected labels back into
+// the model as they come in.
+type Server struct {
+	mu         sync.RWMutex
+	classifier *bayes.Classifier
+	store      feedback.Store
+}
+
+func New(classifier *bayes.Classifier, store feedback.Store) *Server {
+	return &Server{classifier: classifier, store: store}
+}
+
+// Handler serves POST /classify and POST /feedback.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/classify", s.handleClassify)
+	mux.HandleFunc("/feedback", s.handleFeedback)
+	return mux
+}
+
+type classifyRequest struct {
+	Text string `json:"text"`
+}
+
+type classifyResponse struct {
+	Class           bayes.Class `json:"class"`
+	SpamProbability float64     `json:"spamProbability"`
+}
+
+type feedbackRequest struct {
+	// Label is a pointer so a request without one is told apart from ham
+	Label *bayes.Class `json:"label"`
+	Text  string       `json:"text"`
+}
+
+func (s *Server) handleClassify(w http.ResponseWriter, r *http.Request) {
+	var req classifyRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	s.mu.RLock()
+	resp := s.classify(req.Text)
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleFeedback stores the corrected message and learns it, answering with
+// how the message classifies now.
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	var req feedbackRequest
+	if !decode(w, r, &req) {
+		return
+	}
+	if req.Label == nil {
+		http.Error(w, "missing label", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// learn the text as it was stored, so the model matches the one trained
+	// on the store after a restart
+	text, err := s.store.Append(*req.Label, req.Text)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.classifier.Learn(*req.Label, text); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.classify(text))
+}
+
+func (s *Server) classify(text string) classifyResponse {
+	class, p := s.classifier.Score(text)
+	return classifyResponse{Class: class, SpamProbability: p}
+}
+
+// decode reads the JSON body of a POST request, writing the error response
+// and returning false if it cannot.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, fmt.Sprintf("decoding request: %s", err), status)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
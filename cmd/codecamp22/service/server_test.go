@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andreas-holm/codecamp22/bayes"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/feedback"
+	"github.com/andreas-holm/codecamp22/cmd/codecamp22/service"
+)
+
+var examples = []bayes.Example{
+	{Class: bayes.HamClass, Text: "see you at lunch"},
+	{Class: bayes.HamClass, Text: "call me when you are home"},
+	{Class: bayes.SpamClass, Text: "win a free prize now"},
+	{Class: bayes.SpamClass, Text: "call now to claim your prize"},
+}
+
+type response struct {
+	Class           bayes.Class `json:"class"`
+	SpamProbability float64     `json:"spamProbability"`
+}
+
+func newServer(t *testing.T) (http.Handler, *bayes.Classifier, feedback.Store) {
+	classifier := bayes.New(bayes.Options{})
+	classifier.Train(examples)
+	store := feedback.Store{Path: filepath.Join(t.TempDir(), "feedback.data"), Delimiter: ";"}
+	return service.New(classifier, store).Handler(), classifier, store
+}
+
+func post(handler http.Handler, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+func TestClassify(t *testing.T) {
+	handler, _, _ := newServer(t)
+	w := post(handler, "/classify", `{"text": "claim a free prize"}`)
+	var resp response
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&resp) != nil || resp.Class != bayes.SpamClass {
+		t.Errorf("classifying spam: expected 200 and spam, got %d and %+v", w.Code, resp)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/classify", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("classifying with GET: expected 405, got %d", w.Code)
+	}
+	if w := post(handler, "/classify", `{"text": `); w.Code != http.StatusBadRequest {
+		t.Errorf("classifying invalid JSON: expected 400, got %d", w.Code)
+	}
+	large := `{"text": "` + strings.Repeat("free ", service.MaxRequestBytes/5) + `"}`
+	if w := post(handler, "/classify", large); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("classifying a body over %d bytes: expected 413, got %d", service.MaxRequestBytes, w.Code)
+	}
+}
+
+func TestFeedbackErrors(t *testing.T) {
+	handler, classifier, store := newServer(t)
+	for body, what := range map[string]string{
+		`{"text": "free lunch"}`:                  "a missing label",
+		`{"label": "junk", "text": "free lunch"}`: "an invalid label",
+		`{"label": "spam", "text": " "}`:          "empty text",
+	} {
+		if w := post(handler, "/feedback", body); w.Code != http.StatusBadRequest {
+			t.Errorf("feedback with %s: expected 400, got %d", what, w.Code)
+		}
+	}
+	if n := classifier.Messages(bayes.HamClass) + classifier.Messages(bayes.SpamClass); n != len(examples) {
+		t.Errorf("invalid feedback: expected nothing learned, got %d messages", n)
+	}
+	if stored, err := store.Load(); err != nil || len(stored) != 0 {
+		t.Errorf("invalid feedback: expected nothing stored, got %v, %v", stored, err)
+	}
+}
+
+func TestFeedbackRoundTrip(t *testing.T) {
+	handler, classifier, store := newServer(t)
+	w := post(handler, "/feedback", `{"label": "spam", "text": "lunch;at\nhome"}`)
+	var resp response
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&resp) != nil {
+		t.Fatalf("feedback: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	stored, err := store.Load()
+	if err != nil || len(stored) != 1 || stored[0] != (bayes.Example{Class: bayes.SpamClass, Text: "lunch at home"}) {
+		t.Fatalf("feedback: expected the cleaned message stored as spam, got %v, %v", stored, err)
+	}
+	// a server restarted on the store must classify like the one that learned the feedback
+	restarted := bayes.New(bayes.Options{})
+	restarted.Train(append(append([]bayes.Example(nil), examples...), stored...))
+	for _, text := range []string{"lunch at home", "lunch;at", "free prize"} {
+		if a, b := classifier.SpamProbability(text), restarted.SpamProbability(text); math.Abs(a-b) > 1e-12 {
+			t.Errorf("spam probability of %q: expected %f as after a restart, got %f", text, b, a)
+		}
+	}
+	if resp.SpamProbability != classifier.SpamProbability("lunch at home") {
+		t.Errorf("feedback: expected the new spam probability of the message, got %f", resp.SpamProbability)
+	}
+}
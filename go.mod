@@ -3,9 +3,14 @@ module github.com/andreas-holm/codecamp22
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/fatih/color v1.9.0
+	github.com/reiver/go-porterstemmer v1.0.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/mattn/go-isatty v0.0.11 // indirect
-	github.com/reiver/go-porterstemmer v1.0.1
 	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
 )